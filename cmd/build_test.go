@@ -2,6 +2,14 @@ package main
 
 import (
 	"testing"
+
+	crawlconfig "github.com/HuguesGuilleus/isty-search/crawler/config"
 )
 
 func TestBuildOK(t *testing.T) { t.Log("build OK") }
+
+func TestDefaultCrawlConfig(t *testing.T) {
+	if _, err := crawlconfig.Parse(nil, "crawl.json", defaultCrawlConfig); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,30 +2,55 @@ package main
 
 import (
 	"context"
+	_ "embed"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/HuguesGuilleus/isty-search/common"
 	"github.com/HuguesGuilleus/isty-search/crawler"
+	crawlconfig "github.com/HuguesGuilleus/isty-search/crawler/config"
 	crawldatabase "github.com/HuguesGuilleus/isty-search/crawler/database"
-	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt"
+	"github.com/HuguesGuilleus/isty-search/crawler/warc"
 	"github.com/HuguesGuilleus/isty-search/display"
 	"github.com/HuguesGuilleus/isty-search/index"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/metrics"
 	"github.com/HuguesGuilleus/isty-search/search"
 	"github.com/HuguesGuilleus/isty-search/sloghandlers"
 	"golang.org/x/exp/slog"
 )
 
+// The crawl configuration used when no -config flag.
+//
+//go:embed crawl.json
+var defaultCrawlConfig []byte
+
+var crawlConfigFile = flag.String("config", "", "crawl configuration file (JSON), default is the embedded crawl.json")
+
+var (
+	crawlShards = flag.Int("shards", 1, "crawl with this number of processes, the hosts are shared between them")
+	crawlShard  = flag.Int("shard", -1, "crawl only this shard (used by the coordinator with -shards)")
+)
+
+var robotsFile = flag.String("robots", "", "local robots.txt file tested by the robots action, instead of the stored ones")
+
 var actions = map[string]func(logger *slog.Logger, dbbase string) error{
 	"crawl":         mainCrawl,
 	"dbstats":       mainDBStatistics,
+	"warc-export":   mainWARCExport,
+	"warc-import":   mainWARCImport,
+	"merge":         mainMerge,
+	"robots":        mainRobots,
 	"index":         mainIndex,
 	"search":        mainSearch,
 	"demo-vocab":    mainDemoVocab,
@@ -58,43 +83,19 @@ func main() {
 }
 
 func mainCrawl(logger *slog.Logger, dbbase string) error {
-	config := crawler.Config{
-		DBopener: crawldatabase.OpenWithKnow[crawler.Page],
-		DBbase:   dbbase,
-		Input:    common.ParseURLs("https://www.uvsq.fr/"),
-
-		FilterURL: []func(*url.URL) bool{
-			func(u *url.URL) bool {
-				return u.Scheme != "https" || (u.Host != "uvsq.fr" &&
-					u.Host != "cas.uvsq.fr" &&
-					u.Host != "cas2.uvsq.fr" &&
-					!strings.HasSuffix(u.Host, ".uvsq.fr"))
-			},
-		},
-		FilterPage: []func(*htmlnode.Root) bool{
-			func(root *htmlnode.Root) bool {
-				switch root.Meta.Langage {
-				case "":
-					fallthrough
-				case "fr", "fr_FR", "fr-FR", "fr-incl", "fr_incl":
-					return false
-				case "en":
-					// No language log
-					return true
-				default:
-					logger.Info("unknwo_lang", "lang", root.Meta.Langage)
-					return true
-				}
-			},
-		},
-
-		MaxLength: 15_000_000,
-		MaxGo:     10,
-
-		MinCrawlDelay: time.Millisecond * 500,
-		MaxCrawlDelay: time.Second * 10,
-
-		Logger: logger,
+	if *crawlShards > 1 && *crawlShard < 0 {
+		return mainCrawlCoordinator(logger, dbbase)
+	}
+
+	config, err := loadCrawlConfig(logger, dbbase)
+	if err != nil {
+		return err
+	}
+	config.DBopener = crawldatabase.OpenWithRetry[crawler.Page]
+	if *crawlShards > 1 {
+		config.Shard = *crawlShard
+		config.Shards = *crawlShards
+		config.ShardSockets = shardSockets(filepath.Dir(filepath.Clean(dbbase)), *crawlShards)
 	}
 
 	ctx, ctxCancel := signal.NotifyContext(context.Background(), os.Interrupt)
@@ -103,6 +104,251 @@ func mainCrawl(logger *slog.Logger, dbbase string) error {
 	return crawler.Crawl(ctx, config)
 }
 
+// Launch one crawl process by shard, with the database dbbase/shard-i,
+// wait the end of all shards, stop them and merge their databases into
+// dbbase.
+func mainCrawlCoordinator(logger *slog.Logger, dbbase string) error {
+	ctx, ctxCancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer ctxCancel()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	sockets := shardSockets(dbbase, *crawlShards)
+	if err := crawler.CheckShardSockets(sockets); err != nil {
+		return err
+	}
+
+	commands := make([]*exec.Cmd, 0, *crawlShards)
+	stop := func() {
+		for _, command := range commands {
+			command.Process.Signal(os.Interrupt)
+		}
+		for _, command := range commands {
+			command.Wait()
+		}
+		commands = nil
+	}
+	defer stop()
+	for i := 0; i < *crawlShards; i++ {
+		args := []string{"-db", shardBase(dbbase, i), "-shards", strconv.Itoa(*crawlShards), "-shard", strconv.Itoa(i)}
+		if *crawlConfigFile != "" {
+			args = append(args, "-config", *crawlConfigFile)
+		}
+		command := exec.Command(executable, append(args, "crawl")...)
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+		if err := command.Start(); err != nil {
+			return fmt.Errorf("Launch the shard %d: %w", i, err)
+		}
+		commands = append(commands, command)
+		logger.Info("crawl.shard.launch", "shard", i, "pid", command.Process.Pid)
+	}
+
+	err = crawler.WaitShards(ctx, sockets, time.Second*5)
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	stop()
+
+	sources := make([]string, *crawlShards)
+	for i := range sources {
+		sources[i] = shardBase(dbbase, i)
+	}
+	return crawldatabase.Merge[crawler.Page](logger, dbbase, sources...)
+}
+
+// The database of the shard i.
+func shardBase(dbbase string, i int) string {
+	return filepath.Join(dbbase, "shard-"+strconv.Itoa(i))
+}
+
+// The Unix socket of each shard, in its database.
+func shardSockets(dbbase string, shards int) []string {
+	sockets := make([]string, shards)
+	for i := range sockets {
+		sockets[i] = filepath.Join(shardBase(dbbase, i), "shard.sock")
+	}
+	return sockets
+}
+
+// Merge the databases given as arguments (like the shards of a crawl)
+// into the database.
+func mainMerge(logger *slog.Logger, dbbase string) error {
+	if flag.NArg() < 2 {
+		return fmt.Errorf("Need the databases to merge as arguments")
+	}
+	return crawldatabase.Merge[crawler.Page](logger, dbbase, flag.Args()[1:]...)
+}
+
+// Print the robots.txt of the hosts of the URLs given as arguments, and
+// if each URL is allowed with the rule that matched. With the -robots flag,
+// the local file is parsed with the user agent of the crawl configuration,
+// else the robots.txt are read from the database.
+func mainRobots(logger *slog.Logger, dbbase string) error {
+	if flag.NArg() < 2 {
+		return fmt.Errorf("Need the URLs as arguments")
+	}
+	urls := make([]*url.URL, flag.NArg()-1)
+	for i, arg := range flag.Args()[1:] {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return err
+		}
+		urls[i] = u
+	}
+
+	if *robotsFile != "" {
+		config, err := loadCrawlConfig(logger, dbbase)
+		if err != nil {
+			return err
+		}
+		userAgent := config.Fetcher.UserAgent
+		if userAgent == "" {
+			userAgent = crawler.DefaultUserAgent
+		}
+		content, err := os.ReadFile(*robotsFile)
+		if err != nil {
+			return err
+		}
+		robots := robotstxt.Parse(content, userAgent)
+		fmt.Printf("%s (user agent %q)\n", *robotsFile, userAgent)
+		printRobots(os.Stdout, &robots, urls)
+		return nil
+	}
+
+	_, db, err := crawldatabase.Open[crawler.Page](logger, dbbase, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Group the URLs by host, in the arguments order.
+	hosts := make([]url.URL, 0)
+	hostURLs := make(map[url.URL][]*url.URL)
+	for _, u := range urls {
+		robotsURL := crawler.RobotsURL(u.Scheme, u.Host)
+		if hostURLs[robotsURL] == nil {
+			hosts = append(hosts, robotsURL)
+		}
+		hostURLs[robotsURL] = append(hostURLs[robotsURL], u)
+	}
+
+	for i, robotsURL := range hosts {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(robotsURL.String())
+
+		page, _, err := db.GetValue(keys.NewURL(&robotsURL))
+		if err != nil {
+			fmt.Println("\tnot fetched, the URLs are not checked:", err)
+			continue
+		}
+		fmt.Printf("\tstatus: %d %s\n", page.Response.Status, page.Response.URL.String())
+		if fetch := page.RobotsFetch; fetch != nil {
+			fmt.Println("\tfetched:", formatRobotsTime(fetch.Fetched))
+			fmt.Println("\tunreachable since:", formatRobotsTime(fetch.Unreachable))
+			fmt.Println("\texpires:", formatRobotsTime(fetch.Expires))
+		}
+		if page.Robots == nil {
+			fmt.Println("\tunreachable without good copy, all URLs are disallowed")
+			continue
+		}
+		printRobots(os.Stdout, page.Robots, hostURLs[robotsURL])
+	}
+
+	return nil
+}
+
+// Print the robots.txt, then if each URL is allowed and the rule that
+// matched.
+func printRobots(w io.Writer, robots *robotstxt.File, urls []*url.URL) {
+	for _, line := range strings.Split(strings.TrimSuffix(robots.String(), "\n"), "\n") {
+		fmt.Fprintf(w, "\t| %s\n", line)
+	}
+	for _, u := range urls {
+		rule, allow := robots.Match(u)
+		verdict := "disallow"
+		if allow {
+			verdict = "allow"
+		}
+		matched := "no rule"
+		if rule != nil {
+			matched = rule.String()
+		}
+		fmt.Fprintf(w, "\t%-8s %s (%s)\n", verdict, u.String(), matched)
+	}
+}
+
+func formatRobotsTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Load the crawl configuration from the -config flag or the embedded one.
+func loadCrawlConfig(logger *slog.Logger, dbbase string) (crawler.Config, error) {
+	config, err := crawlconfig.Parse(logger, "crawl.json", defaultCrawlConfig)
+	if *crawlConfigFile != "" {
+		config, err = crawlconfig.Load(logger, *crawlConfigFile)
+	}
+	if err != nil {
+		return crawler.Config{}, err
+	}
+	config.DBbase = dbbase
+	config.Logger = logger
+	return config, nil
+}
+
+// Export the database into the WARC file given as argument, compressed if
+// the name ends with ".gz".
+func mainWARCExport(logger *slog.Logger, dbbase string) error {
+	output := flag.Arg(1)
+	if output == "" {
+		return fmt.Errorf("Need the output WARC file as argument")
+	}
+
+	_, db, err := crawldatabase.Open[crawler.Page](logger, dbbase, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := crawler.ExportWARC(db, warc.NewWriter(f, strings.HasSuffix(output, ".gz"))); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Import the WARC files given as arguments into the database, with the
+// filters of the crawl configuration.
+func mainWARCImport(logger *slog.Logger, dbbase string) error {
+	if flag.NArg() < 2 {
+		return fmt.Errorf("Need the WARC files as arguments")
+	}
+
+	config, err := loadCrawlConfig(logger, dbbase)
+	if err != nil {
+		return err
+	}
+	config.DBopener = crawldatabase.Open[crawler.Page]
+
+	ctx, ctxCancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer ctxCancel()
+
+	return crawler.ImportWARC(ctx, config, flag.Args()[1:]...)
+}
+
 func mainDBStatistics(logger *slog.Logger, dbbase string) error {
 	_, db, err := crawldatabase.Open[crawler.Page](logger, dbbase, false)
 	if err != nil {
@@ -177,9 +423,11 @@ func mainIndex(logger *slog.Logger, dbbase string) error {
 	}
 	defer db.Close()
 
+	redirections := db.Redirections()
 	reverseIndex := make(index.ReverseIndex)
-	links := index.NewLinks(db.Redirections())
-	if err := crawler.Process(db, &links, reverseIndex); err != nil {
+	links := index.NewLinks(redirections)
+	anchors := index.NewAnchors(redirections)
+	if err := crawler.Process(db, &links, reverseIndex, anchors); err != nil {
 		return err
 	}
 
@@ -191,6 +439,7 @@ func mainIndex(logger *slog.Logger, dbbase string) error {
 	}
 
 	// Reverse index
+	anchors.AddTo(reverseIndex, index.AnchorWeight)
 	reverseIndex.Sort()
 	if err := reverseIndex.Store(filepath.Join(dbbase, "words.db")); err != nil {
 		return err
@@ -216,12 +465,16 @@ func mainSearch(logger *slog.Logger, dbbase string) error {
 		return err
 	}
 
-	logger.Info("listen", "address", ":8000")
-	return http.ListenAndServe(":8000", display.Handler(logger, &search.DB{
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default)
+	mux.Handle("/", display.Handler(logger, &search.DB{
 		CrawlerDB:    db,
 		ReverseIndex: wordsIndex,
 		GlobalScore:  pageRank,
 	}))
+
+	logger.Info("listen", "address", ":8000")
+	return http.ListenAndServe(":8000", mux)
 }
 
 func mainDemoSearch(logger *slog.Logger, _ string) error {
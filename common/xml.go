@@ -0,0 +1,25 @@
+package common
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// Get the local name of the first XML element. Return an empty string if
+// the data is not XML. The charsetReader (can be nil) is used for the
+// documents not in UTF-8, see xml.Decoder.CharsetReader.
+func XMLRootName(data []byte, charsetReader func(charset string, input io.Reader) (io.Reader, error)) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+	for {
+		token, err := decoder.RawToken()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
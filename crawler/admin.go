@@ -0,0 +1,240 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/metrics"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The interval to check if the paused crawl is resumed, by the last worker.
+const pausePoll = time.Second
+
+// Check that the admin API address is a loopback address, the API has no
+// authentication. A host name must resolve only to loopback addresses.
+func checkAdminAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("Wrong admin address %q: %w", address, err)
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil && host != "" {
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("Resolve the admin address %q: %w", address, err)
+		}
+	}
+	for _, ip := range ips {
+		if ip == nil || !ip.IsLoopback() {
+			return fmt.Errorf("The admin address %q is not a loopback address", address)
+		}
+	}
+	return nil
+}
+
+// The state of the crawl, returned by the admin API at /status.
+type AdminStatus struct {
+	Paused  bool              `json:"paused"`
+	MaxGo   int               `json:"maxGo"`
+	Workers int               `json:"workers"`
+	Hosts   []AdminHostStatus `json:"hosts"`
+
+	// For a sharded crawl: the URLs waiting to be forwarded to the other
+	// shards, and the URLs sent to and received from the other shards.
+	ShardPending  int   `json:"shardPending,omitempty"`
+	ShardSent     int64 `json:"shardSent,omitempty"`
+	ShardReceived int64 `json:"shardReceived,omitempty"`
+}
+
+// The state of one host.
+type AdminHostStatus struct {
+	// The scheme and the host, like "https://example.org".
+	Host   string `json:"host"`
+	Queue  int    `json:"queue"`
+	Paused bool   `json:"paused"`
+	// The URL being fetched, empty if none.
+	InFlight string `json:"inFlight,omitempty"`
+	// The last crawl delay, like "1.5s", empty before the first fetch.
+	CrawlDelay string    `json:"crawlDelay,omitempty"`
+	NotBefore  time.Time `json:"notBefore"`
+}
+
+// The admin API to control a running crawl:
+//   - GET /status: the AdminStatus as JSON.
+//   - POST /pause and /resume: pause or resume the crawl, or a host with
+//     the parameter host, like "https://example.org".
+//   - POST /seeds: add the URLs of the body (one by line) with depth 0,
+//     rejected with 503 after the end of the crawl.
+//   - POST /maxgo: change MaxGo with the parameter n.
+//   - POST /forward: for a sharded crawl, add the URLs forwarded by an
+//     other shard, a JSON array of shardURL.
+//   - GET /metrics: the metrics.Default in the Prometheus format.
+//
+// There is no authentication: listen only on a loopback address, the shard
+// Unix socket is only accessible by its owner.
+func (ctx *fetchContext) adminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			metrics.Default.ServeHTTP(w, r)
+			return
+		} else if r.URL.Path == "/status" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Need method GET", http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(ctx.adminStatus())
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "Need method POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/pause", "/resume":
+			paused := r.URL.Path == "/pause"
+			hostKey := ""
+			if host := r.FormValue("host"); host != "" {
+				u, err := url.Parse(host)
+				if err != nil || u.Host == "" {
+					http.Error(w, "Wrong host, need like 'https://example.org'", http.StatusBadRequest)
+					return
+				}
+				hostKey = createKey(u.Scheme, u.Host)
+			}
+			ctx.adminPause(hostKey, paused)
+			ctx.logger.Info("crawl.admin.pause", "host", r.FormValue("host"), "paused", paused)
+
+		case "/seeds":
+			urls := make(map[keys.Key]*url.URL)
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" {
+					continue
+				}
+				u, err := url.Parse(line)
+				if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+					http.Error(w, "Wrong URL: "+strconv.Quote(line), http.StatusBadRequest)
+					return
+				}
+				cleanURL(u)
+				urls[keys.NewURL(u)] = u
+			}
+			if err := scanner.Err(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ctx.hostsMutex.Lock()
+			closed := ctx.closed
+			ctx.hostsMutex.Unlock()
+			if closed {
+				http.Error(w, "The crawl is over", http.StatusServiceUnavailable)
+				return
+			}
+			ctx.addURLs(nil, urls, nil, 0)
+			ctx.logger.Info("crawl.admin.seeds", "len", len(urls))
+
+		case "/maxgo":
+			n, err := strconv.Atoi(r.FormValue("n"))
+			if err != nil || n < 1 {
+				http.Error(w, "Need a positive n", http.StatusBadRequest)
+				return
+			}
+			ctx.adminMaxGo(n)
+			ctx.logger.Info("crawl.admin.maxgo", "n", n)
+
+		case "/forward":
+			if ctx.shards == nil {
+				http.Error(w, "The crawl is not sharded", http.StatusNotFound)
+				return
+			}
+			items := []shardURL(nil)
+			if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := ctx.receiveShardURLs(items); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (ctx *fetchContext) adminStatus() AdminStatus {
+	ctx.hostsMutex.Lock()
+	defer ctx.hostsMutex.Unlock()
+
+	status := AdminStatus{
+		Paused:  ctx.paused,
+		MaxGo:   ctx.maxGo,
+		Workers: ctx.lenGo,
+		Hosts:   make([]AdminHostStatus, 0, len(ctx.hosts)),
+	}
+	for key, h := range ctx.hosts {
+		hostStatus := AdminHostStatus{
+			Host:      h.scheme + "://" + h.host,
+			Queue:     len(h.queue),
+			Paused:    ctx.pausedHosts[key],
+			NotBefore: h.state.NotBefore,
+		}
+		if u := ctx.inFlight[key]; u != nil {
+			hostStatus.InFlight = u.String()
+		}
+		if h.delay > 0 {
+			hostStatus.CrawlDelay = h.delay.String()
+		}
+		status.Hosts = append(status.Hosts, hostStatus)
+	}
+	sort.Slice(status.Hosts, func(i, j int) bool { return status.Hosts[i].Host < status.Hosts[j].Host })
+
+	if ctx.shards != nil {
+		status.ShardPending = ctx.shards.getPending()
+		status.ShardSent = ctx.shards.sent.Load()
+		status.ShardReceived = ctx.shards.received.Load()
+	}
+
+	return status
+}
+
+// Pause or resume the host, or the whole crawl if hostKey is empty.
+func (ctx *fetchContext) adminPause(hostKey string, paused bool) {
+	ctx.hostsMutex.Lock()
+	defer ctx.hostsMutex.Unlock()
+
+	if hostKey == "" {
+		ctx.paused = paused
+	} else if paused {
+		ctx.pausedHosts[hostKey] = true
+	} else {
+		delete(ctx.pausedHosts, hostKey)
+	}
+
+	if !paused {
+		ctx.startWorkers()
+	}
+}
+
+func (ctx *fetchContext) adminMaxGo(n int) {
+	ctx.hostsMutex.Lock()
+	defer ctx.hostsMutex.Unlock()
+	ctx.maxGo = n
+	ctx.startWorkers()
+}
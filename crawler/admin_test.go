@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx := &fetchContext{
+		db:           db,
+		hosts:        make(map[string]*host),
+		parked:       make(map[string]bool),
+		hostsPlanned: make(map[string]int),
+		pausedHosts:  make(map[string]bool),
+		inFlight:     make(map[string]*url.URL),
+		logger:       slog.New(sloghandlers.NewNullHandler()),
+		context:      canceled,
+		maxGo:        0, // No worker is launched
+	}
+	ctx.workerEnd.L = &ctx.hostsMutex
+	handler := ctx.adminHandler()
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	getStatus := func() (status AdminStatus) {
+		t.Helper()
+		w := request(http.MethodGet, "/status", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return
+	}
+
+	// Wrong requests
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/pause", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/yolo", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/seeds", "ftp://example.org/").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/maxgo?n=0", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/pause?host=example.org", "").Code)
+
+	// Seeds
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/seeds",
+		"https://example.org/a\n\nhttps://example.org/b#top\nhttps://other.org/\n").Code)
+	assert.Equal(t, AdminStatus{Hosts: []AdminHostStatus{
+		{Host: "https://example.org", Queue: 2},
+		{Host: "https://other.org", Queue: 1},
+	}}, getStatus())
+
+	// Pause a host
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/pause?host=https://example.org", "").Code)
+	assert.True(t, getStatus().Hosts[0].Paused)
+	ctx.lenGo, ctx.maxGo = 1, 1
+	b := ctx.tryChooseWork(nil)
+	assert.Equal(t, "other.org", b.host)
+	b.delay = time.Second
+	ctx.setInFlight("https:other.org", b.items[0].url)
+	assert.Equal(t, AdminStatus{MaxGo: 1, Workers: 1, Hosts: []AdminHostStatus{
+		{Host: "https://example.org", Queue: 2, Paused: true},
+		{Host: "https://other.org", InFlight: "https://other.org/"},
+	}}, getStatus())
+	ctx.setInFlight("https:other.org", nil)
+	b.items = nil
+
+	// The last worker wait the paused host.
+	b = ctx.tryChooseWork(b)
+	assert.NotNil(t, b)
+	assert.False(t, b.wait.IsZero())
+	assert.Nil(t, ctx.hosts["https:other.org"])
+
+	// Pause the crawl
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/resume?host=https://example.org", "").Code)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/pause", "").Code)
+	assert.True(t, getStatus().Paused)
+	assert.False(t, getStatus().Hosts[0].Paused)
+	assert.False(t, ctx.setInFlight("https:example.org", common.ParseURL("https://example.org/a")))
+	b = ctx.tryChooseWork(b)
+	assert.NotNil(t, b)
+	assert.False(t, b.wait.IsZero())
+
+	// Resume and change MaxGo, the worker is launched and stop with the
+	// canceled context.
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/resume", "").Code)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/maxgo?n=2", "").Code)
+	ctx.wg.Wait()
+	status := getStatus()
+	assert.False(t, status.Paused)
+	assert.Equal(t, 2, status.MaxGo)
+
+	// The crawl is over, without the test worker.
+	ctx.lenGo--
+	ctx.closeWorkers()
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodPost, "/seeds", "https://example.org/new").Code)
+	assert.Equal(t, 0, getStatus().Workers)
+}
+
+func TestCheckAdminAddress(t *testing.T) {
+	assert.NoError(t, checkAdminAddress("localhost:8001"))
+	assert.NoError(t, checkAdminAddress("127.0.0.1:8001"))
+	assert.NoError(t, checkAdminAddress("[::1]:8001"))
+	assert.Error(t, checkAdminAddress(":8001"))
+	assert.Error(t, checkAdminAddress("0.0.0.0:8001"))
+	assert.Error(t, checkAdminAddress("192.168.1.1:8001"))
+	assert.Error(t, checkAdminAddress("localhost"))
+}
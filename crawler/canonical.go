@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/url"
+	"strings"
+)
+
+// Get the canonical URL from the Link headers (`<url>; rel=canonical`),
+// or nil if there is no canonical link.
+func getLinkCanonical(u *url.URL, links []string) *url.URL {
+	for _, link := range links {
+		for _, value := range strings.Split(link, ",") {
+			target, params, _ := strings.Cut(value, ";")
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(param, "=")
+				if strings.TrimSpace(name) != "rel" {
+					continue
+				}
+				for _, r := range strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(rel), `"`))) {
+					if r != "canonical" {
+						continue
+					} else if ref, err := url.Parse(target[1 : len(target)-1]); err == nil {
+						return absoluteURL(u, ref)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Get the absolute canonical URL of the page, the Link header has priority
+// over the <link rel=canonical>. Return nil if the page is its own canonical.
+func getCanonical(u *url.URL, header *url.URL, meta *htmlnode.Meta) *url.URL {
+	canonical := header
+	if canonical == nil && meta.Canonical != (url.URL{}) {
+		canonical = absoluteURL(baseURL(u, meta), &meta.Canonical)
+	}
+	if canonical == nil || keys.NewURL(canonical) == keys.NewURL(u) {
+		return nil
+	}
+	return canonical
+}
+
+// Save u as an alias of the canonical URL, and add the canonical URL to the
+// crawl. Return false (and save nothing) if the canonical URL is filtered
+// or is itself an alias, so the page is saved.
+func (ctx *fetchContext) saveCanonical(u, canonical *url.URL, depth int) bool {
+	for _, filter := range ctx.filterURL {
+		if filter(canonical) {
+			return false
+		}
+	}
+
+	canonicalKey := keys.NewURL(canonical)
+	if t := ctx.db.GetType(canonicalKey); crawldatabase.TypeAlias <= t && t < crawldatabase.TypeError {
+		// Avoid alias loop.
+		return false
+	}
+
+	ctx.addURLs(u, map[keys.Key]*url.URL{canonicalKey: canonical}, nil, depth)
+	ctx.db.SetAlias(keys.NewURL(u), canonicalKey, crawldatabase.TypeAliasCanonical)
+
+	return true
+}
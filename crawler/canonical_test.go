@@ -0,0 +1,31 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestGetLinkCanonical(t *testing.T) {
+	u := common.ParseURL("https://example.org/article?page=1")
+
+	assert.Nil(t, getLinkCanonical(u, nil))
+	assert.Nil(t, getLinkCanonical(u, []string{`</style.css>; rel=preload`}))
+	assert.Equal(t, common.ParseURL("https://example.org/article"),
+		getLinkCanonical(u, []string{`</style.css>; rel=preload, </article>; rel="canonical"`}))
+	assert.Equal(t, common.ParseURL("https://example.com/a"),
+		getLinkCanonical(u, []string{`</style.css>; rel=preload`, `<https://example.com/a#top>; title="A"; rel=Canonical`}))
+}
+
+func TestGetCanonical(t *testing.T) {
+	u := common.ParseURL("http://example.org/article?utm_source=x")
+	header := common.ParseURL("https://example.org/article")
+
+	assert.Nil(t, getCanonical(u, nil, &htmlnode.Meta{}))
+	assert.Nil(t, getCanonical(u, nil, &htmlnode.Meta{Canonical: url.URL{RawQuery: "utm_source=x"}}))
+	assert.Equal(t, header, getCanonical(u, header, &htmlnode.Meta{Canonical: url.URL{Path: "/other"}}))
+	assert.Equal(t, common.ParseURL("http://example.org/article"),
+		getCanonical(u, nil, &htmlnode.Meta{Canonical: url.URL{Path: "/article"}}))
+}
@@ -0,0 +1,211 @@
+// Detect the charset of a HTML document and transcode it to UTF-8.
+//
+// Supported charsets: UTF-8, UTF-16 (LE and BE), Windows-1252 (also used
+// for ISO-8859-1 and ASCII, like the browsers) and ISO-8859-15.
+//
+// Specification: https://html.spec.whatwg.org/multipage/parsing.html#determining-the-character-encoding
+package charset
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	Windows1252 = "windows-1252"
+	ISO885915   = "iso-8859-15"
+)
+
+// The meta charset is searched only in the beginning of the document.
+const metaPrescanLength = 1024
+
+// Detect the charset from the BOM, then the Content-Type header, then the
+// <meta charset> (UTF-16 is read as UTF-8, like the browsers). If nothing is found, return UTF8 if the data is valid
+// UTF-8, else Windows1252.
+//
+// Unsupported charset are returned as it, so ToUTF8 do not modify the data.
+func Detect(contentType string, data []byte) string {
+	if charset := bom(data); charset != "" {
+		return charset
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if charset := Normalize(params["charset"]); charset != "" {
+			return charset
+		}
+	}
+
+	switch charset := Normalize(metaCharset(data)); charset {
+	case "":
+	case UTF16LE, UTF16BE:
+		// An ASCII compatible meta can not be UTF-16, see the specification.
+		return UTF8
+	default:
+		return charset
+	}
+
+	if utf8.Valid(data) {
+		return UTF8
+	}
+	return Windows1252
+}
+
+// Get the charset from the Byte Order Mark, or empty string.
+func bom(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+	return ""
+}
+
+// Search the charset in a <meta charset="..."> or in a
+// <meta http-equiv="Content-Type" content="text/html; charset=...">.
+func metaCharset(data []byte) string {
+	if len(data) > metaPrescanLength {
+		data = data[:metaPrescanLength]
+	}
+	head := bytes.ToLower(data)
+
+	for {
+		i := bytes.Index(head, []byte("<meta"))
+		if i < 0 {
+			return ""
+		}
+		head = head[i+5:]
+
+		end := bytes.IndexByte(head, '>')
+		if end < 0 {
+			end = len(head)
+		}
+		tag := head[:end]
+
+		j := bytes.Index(tag, []byte("charset"))
+		if j < 0 {
+			continue
+		}
+		value := bytes.TrimLeft(tag[j+7:], " \t\n\r")
+		if len(value) == 0 || value[0] != '=' {
+			continue
+		}
+		value = bytes.TrimLeft(value[1:], " \t\n\r\"'")
+		if k := bytes.IndexAny(value, " \t\n\r\"';/"); k >= 0 {
+			value = value[:k]
+		}
+		return string(value)
+	}
+}
+
+// Get the canonical name of a charset label. Unknown label are returned
+// in lower case, empty label returns empty string.
+func Normalize(label string) string {
+	label = strings.ToLower(strings.Trim(label, " \t\n\r\"'"))
+	switch label {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+		return UTF8
+	case "utf-16le", "utf-16":
+		return UTF16LE
+	case "utf-16be":
+		return UTF16BE
+	case "windows-1252", "cp1252", "x-cp1252",
+		"iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "cp819", "ibm819",
+		"us-ascii", "ascii", "ansi_x3.4-1968":
+		return Windows1252
+	case "iso-8859-15", "iso8859-15", "iso_8859-15", "latin-9", "l9", "csisolatin9":
+		return ISO885915
+	}
+	return label
+}
+
+// Transcode data from the charset (see Detect) to UTF-8. The BOM is removed.
+// If the charset is unsupported, data is returned unmodified.
+func ToUTF8(charset string, data []byte) []byte {
+	switch charset {
+	case UTF8:
+		return bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	case UTF16LE, UTF16BE:
+		return fromUTF16(charset == UTF16BE, data)
+	case Windows1252:
+		return fromTable(&windows1252, data)
+	case ISO885915:
+		return fromTable(&iso885915, data)
+	}
+	return data
+}
+
+// Return a reader that transcode input into UTF-8. Used by xml.Decoder.
+func NewReader(label string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(ToUTF8(Normalize(label), data)), nil
+}
+
+func fromUTF16(bigEndian bool, data []byte) []byte {
+	if len(data) >= 2 && (data[0] == 0xFF && data[1] == 0xFE || data[0] == 0xFE && data[1] == 0xFF) {
+		data = data[2:]
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+
+	return []byte(string(utf16.Decode(units)))
+}
+
+// A single-byte charset, the table contains runes for bytes 0x80..0xFF.
+type table [128]rune
+
+func fromTable(t *table, data []byte) []byte {
+	buff := make([]byte, 0, len(data)+len(data)/4)
+	for _, b := range data {
+		if b < 0x80 {
+			buff = append(buff, b)
+		} else {
+			buff = utf8.AppendRune(buff, t[b-0x80])
+		}
+	}
+	return buff
+}
+
+var windows1252, iso885915 table
+
+func init() {
+	for i := range windows1252 {
+		windows1252[i] = rune(0x80 + i)
+		iso885915[i] = rune(0x80 + i)
+	}
+
+	copy(windows1252[:0x20], []rune{
+		0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+		0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	})
+
+	iso885915[0xA4-0x80] = 0x20AC
+	iso885915[0xA6-0x80] = 0x0160
+	iso885915[0xA8-0x80] = 0x0161
+	iso885915[0xB4-0x80] = 0x017D
+	iso885915[0xB8-0x80] = 0x017E
+	iso885915[0xBC-0x80] = 0x0152
+	iso885915[0xBD-0x80] = 0x0153
+	iso885915[0xBE-0x80] = 0x0178
+}
@@ -0,0 +1,33 @@
+package charset
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, UTF8, Detect("", []byte("\xEF\xBB\xBFabc")))
+	assert.Equal(t, UTF16LE, Detect("text/html; charset=iso-8859-1", []byte("\xFF\xFEa\x00")))
+	assert.Equal(t, UTF16BE, Detect("", []byte("\xFE\xFF\x00a")))
+
+	assert.Equal(t, Windows1252, Detect("text/html; charset=ISO-8859-1", []byte("<meta charset=utf-8>")))
+	assert.Equal(t, ISO885915, Detect(`text/html;charset="latin-9"`, nil))
+
+	assert.Equal(t, Windows1252, Detect("text/html", []byte(`<!DOCTYPE html><meta charset="iso-8859-1"><title>`)))
+	assert.Equal(t, Windows1252, Detect("", []byte(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=windows-1252">`)))
+	assert.Equal(t, "shift_jis", Detect("", []byte(`<meta name="description" content="yolo"><meta charset='Shift_JIS'/>`)))
+	assert.Equal(t, UTF8, Detect("", []byte(`<meta charset="utf-16">`)))
+	assert.Equal(t, UTF8, Detect("", []byte(`<meta http-equiv="Content-Type" content="text/html; charset=UTF-16LE">`)))
+
+	assert.Equal(t, UTF8, Detect("", []byte("<p>été</p>")))
+	assert.Equal(t, Windows1252, Detect("", []byte("<p>\xE9t\xE9</p>")))
+}
+
+func TestToUTF8(t *testing.T) {
+	assert.Equal(t, "abc", string(ToUTF8(UTF8, []byte("\xEF\xBB\xBFabc"))))
+	assert.Equal(t, "été € œ", string(ToUTF8(Windows1252, []byte("\xE9t\xE9 \x80 \x9C"))))
+	assert.Equal(t, "été € œ", string(ToUTF8(ISO885915, []byte("\xE9t\xE9 \xA4 \xBD"))))
+	assert.Equal(t, "aé\U0001F600", string(ToUTF8(UTF16LE, []byte("\xFF\xFEa\x00\xE9\x00\x3D\xD8\x00\xDE"))))
+	assert.Equal(t, "aé", string(ToUTF8(UTF16BE, []byte("\x00a\x00\xE9"))))
+	assert.Equal(t, "\x82\xa0", string(ToUTF8("shift_jis", []byte("\x82\xa0"))))
+}
@@ -0,0 +1,579 @@
+// Load the crawl configuration from a JSON file, and compile it into a
+// crawler.Config.
+package crawlconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"golang.org/x/exp/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The crawl configuration file.
+//
+// The patterns of hosts and paths are glob, where "*" match any
+// characters (also "/" and "."), or regular expressions with the prefix
+// "re:". The host is without the port.
+type File struct {
+	// The root URLs.
+	Seeds []string `json:"seeds"`
+
+	// Accepted schemes, default is https and http.
+	Schemes []string `json:"schemes"`
+	// If not empty, only the matching hosts are crawled.
+	IncludeHosts []string `json:"includeHosts"`
+	ExcludeHosts []string `json:"excludeHosts"`
+	// If not empty, only the matching paths are crawled.
+	IncludePaths []string `json:"includePaths"`
+	ExcludePaths []string `json:"excludePaths"`
+
+	// The accepted languages of the pages (the attribute lang). Use an
+	// empty string for pages without language. The language "fr" accept
+	// also "fr-FR" or "fr_incl". If empty, accept all pages.
+	Languages []string `json:"languages"`
+
+	MaxGo           int    `json:"maxGo"`
+	MaxLength       int64  `json:"maxLength"`
+	MaxDepth        int    `json:"maxDepth"`
+	MaxPagesPerHost int    `json:"maxPagesPerHost"`
+	MaxHostErrors   int    `json:"maxHostErrors"`
+	UserAgent       string `json:"userAgent"`
+
+	// Durations, parsed by time.ParseDuration, for exemple "1m30s".
+	// Default crawl delays: 500ms and 10s.
+	MinCrawlDelay    string `json:"minCrawlDelay"`
+	MaxCrawlDelay    string `json:"maxCrawlDelay"`
+	HostParkDuration string `json:"hostParkDuration"`
+	RevisitAge       string `json:"revisitAge"`
+	RevisitMaxAge    string `json:"revisitMaxAge"`
+
+	// The listen address of the admin HTTP API, like "localhost:8001".
+	// Empty to disable it.
+	AdminAddress string `json:"adminAddress"`
+
+	// Limit or disable the speculative URLs added from each link.
+	Speculative Speculative `json:"speculative"`
+
+	// Overrides by host (without the port).
+	Hosts map[string]Host `json:"hosts"`
+}
+
+// See crawler.Speculative.
+type Speculative struct {
+	DisableQuery  bool `json:"disableQuery"`
+	DisablePort   bool `json:"disablePort"`
+	DisableWWW    bool `json:"disableWWW"`
+	MaxParentPath int  `json:"maxParentPath"`
+	MaxParentHost int  `json:"maxParentHost"`
+}
+
+// The overrides for one host.
+type Host struct {
+	// The weight of the host, see crawler.Config.HostWeight.
+	Weight float64 `json:"weight"`
+	// If not nil, replace the global IncludePaths and ExcludePaths.
+	IncludePaths []string `json:"includePaths"`
+	ExcludePaths []string `json:"excludePaths"`
+	// If not empty, replace the global values.
+	MinCrawlDelay string `json:"minCrawlDelay"`
+	MaxCrawlDelay string `json:"maxCrawlDelay"`
+	MaxLength     int64  `json:"maxLength"`
+}
+
+// An error in the configuration file.
+type Error struct {
+	// The file name and the line, zero if unknown.
+	File string
+	Line int
+	// The path of the JSON value, like "seeds.2" or "hosts.example.org.weight".
+	Path string
+	Err  error
+}
+
+func (err *Error) Error() string {
+	s := err.File
+	if err.Line > 0 {
+		s += ":" + strconv.Itoa(err.Line)
+	}
+	if err.Path != "" {
+		s += ": " + err.Path
+	}
+	return s + ": " + err.Err.Error()
+}
+
+func (err *Error) Unwrap() error { return err.Err }
+
+// Read the configuration file, see Parse.
+func Load(logger *slog.Logger, name string) (crawler.Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return crawler.Config{}, err
+	}
+	return Parse(logger, name, data)
+}
+
+// Parse the configuration file, and compile it with the logger (see
+// Compile). The name is used in the errors. On error, it return *Error.
+func Parse(logger *slog.Logger, name string, data []byte) (crawler.Config, error) {
+	file := File{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		e := &Error{File: name, Err: err}
+		syntaxError := (*json.SyntaxError)(nil)
+		typeError := (*json.UnmarshalTypeError)(nil)
+		if errors.As(err, &syntaxError) {
+			e.Line = lineOf(data, syntaxError.Offset)
+		} else if errors.As(err, &typeError) {
+			e.Line = lineOf(data, typeError.Offset)
+			e.Path = typeError.Field
+		} else if prefix := "json: unknown field "; strings.HasPrefix(err.Error(), prefix) {
+			field, _ := strconv.Unquote(err.Error()[len(prefix):])
+			if e.Path = unknownFieldPath(data, field); e.Path != "" {
+				e.Line = lineOf(data, valueOffsets(data)[e.Path])
+			}
+		}
+		return crawler.Config{}, e
+	}
+
+	config, err := file.Compile(logger)
+	if e := (*Error)(nil); errors.As(err, &e) {
+		e.File = name
+		if offset, ok := valueOffsets(data)[e.Path]; ok {
+			e.Line = lineOf(data, offset)
+		}
+	}
+	return config, err
+}
+
+// Compile the file into a crawler config. The unknown languages of the
+// striked pages are logged once with the logger (can be nil). On error,
+// it return *Error with the path (without the file name and the line).
+func (file *File) Compile(logger *slog.Logger) (config crawler.Config, err error) {
+	// Seeds
+	for i, seed := range file.Seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return config, pathError(err, "seeds", i)
+		} else if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+			return config, pathError(fmt.Errorf("%q is not an absolute HTTP URL", seed), "seeds", i)
+		}
+		config.Input = append(config.Input, u)
+	}
+
+	// Numbers
+	for _, number := range [...]struct {
+		name  string
+		value int64
+	}{
+		{"maxGo", int64(file.MaxGo)},
+		{"maxLength", file.MaxLength},
+		{"maxDepth", int64(file.MaxDepth)},
+		{"maxPagesPerHost", int64(file.MaxPagesPerHost)},
+		{"maxHostErrors", int64(file.MaxHostErrors)},
+	} {
+		if number.value < 0 {
+			return config, pathError(fmt.Errorf("must be positive, get %d", number.value), number.name)
+		}
+	}
+	config.MaxGo = file.MaxGo
+	if config.MaxGo == 0 {
+		config.MaxGo = 1
+	}
+	config.MaxLength = file.MaxLength
+	if config.MaxLength == 0 {
+		config.MaxLength = 15_000_000
+	}
+	config.MaxDepth = file.MaxDepth
+	config.MaxPagesPerHost = file.MaxPagesPerHost
+	config.MaxHostErrors = file.MaxHostErrors
+	config.Fetcher.UserAgent = file.UserAgent
+	config.Speculative = crawler.Speculative(file.Speculative)
+	config.AdminAddress = file.AdminAddress
+
+	// Durations
+	config.MinCrawlDelay = time.Millisecond * 500
+	config.MaxCrawlDelay = time.Second * 10
+	for _, duration := range [...]struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"minCrawlDelay", file.MinCrawlDelay, &config.MinCrawlDelay},
+		{"maxCrawlDelay", file.MaxCrawlDelay, &config.MaxCrawlDelay},
+		{"hostParkDuration", file.HostParkDuration, &config.HostParkDuration},
+		{"revisitAge", file.RevisitAge, &config.RevisitAge},
+		{"revisitMaxAge", file.RevisitMaxAge, &config.RevisitMaxAge},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return config, pathError(err, duration.name)
+		} else if d < 0 {
+			return config, pathError(fmt.Errorf("must be positive, get %s", d), duration.name)
+		}
+		*duration.target = d
+	}
+	if config.MaxCrawlDelay < config.MinCrawlDelay {
+		return config, pathError(fmt.Errorf("must be greater than minCrawlDelay"), "maxCrawlDelay")
+	}
+
+	// URL filter
+	filter := urlFilter{
+		schemes: file.Schemes,
+		hosts:   make(map[string]hostFilter, len(file.Hosts)),
+	}
+	if len(filter.schemes) == 0 {
+		filter.schemes = []string{"https", "http"}
+	}
+	if filter.includeHosts, err = compilePatterns(file.IncludeHosts, "includeHosts"); err != nil {
+		return config, err
+	} else if filter.excludeHosts, err = compilePatterns(file.ExcludeHosts, "excludeHosts"); err != nil {
+		return config, err
+	} else if filter.includePaths, err = compilePatterns(file.IncludePaths, "includePaths"); err != nil {
+		return config, err
+	} else if filter.excludePaths, err = compilePatterns(file.ExcludePaths, "excludePaths"); err != nil {
+		return config, err
+	}
+	for name, host := range file.Hosts {
+		if host.Weight < 0 {
+			return config, pathError(fmt.Errorf("must be positive, get %g", host.Weight), "hosts", name, "weight")
+		} else if host.Weight > 0 {
+			if config.HostWeight == nil {
+				config.HostWeight = make(map[string]float64)
+			}
+			config.HostWeight[name] = host.Weight
+		}
+
+		limits, err := host.limits(config.MinCrawlDelay, config.MaxCrawlDelay, name)
+		if err != nil {
+			return config, err
+		} else if limits != (crawler.HostLimits{}) {
+			if config.HostLimits == nil {
+				config.HostLimits = make(map[string]crawler.HostLimits)
+			}
+			config.HostLimits[name] = limits
+		}
+
+		hf := hostFilter{
+			includePaths: filter.includePaths,
+			excludePaths: filter.excludePaths,
+		}
+		if host.IncludePaths != nil {
+			if hf.includePaths, err = compilePatterns(host.IncludePaths, "hosts", name, "includePaths"); err != nil {
+				return config, err
+			}
+		}
+		if host.ExcludePaths != nil {
+			if hf.excludePaths, err = compilePatterns(host.ExcludePaths, "hosts", name, "excludePaths"); err != nil {
+				return config, err
+			}
+		}
+		filter.hosts[name] = hf
+	}
+	config.FilterURL = []func(*url.URL) bool{filter.strike}
+
+	// Page filter
+	if len(file.Languages) > 0 {
+		languages := make([]string, len(file.Languages))
+		for i, lang := range file.Languages {
+			languages[i] = normalizeLanguage(lang)
+		}
+		unknown := sync.Map{}
+		config.FilterPage = []func(*htmlnode.Root) bool{func(root *htmlnode.Root) bool {
+			lang := normalizeLanguage(root.Meta.Langage)
+			if acceptLanguage(languages, lang) {
+				return false
+			}
+			if _, logged := unknown.LoadOrStore(lang, true); !logged && logger != nil {
+				logger.Warn("crawl.unknown_lang", "lang", root.Meta.Langage)
+			}
+			return true
+		}}
+	}
+
+	return config, nil
+}
+
+// Compile the limits of the host name, with the global crawl delays.
+func (host *Host) limits(minCrawlDelay, maxCrawlDelay time.Duration, name string) (limits crawler.HostLimits, err error) {
+	if host.MaxLength < 0 {
+		return limits, pathError(fmt.Errorf("must be positive, get %d", host.MaxLength), "hosts", name, "maxLength")
+	}
+	limits.MaxLength = host.MaxLength
+
+	for _, duration := range [...]struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"minCrawlDelay", host.MinCrawlDelay, &limits.MinCrawlDelay},
+		{"maxCrawlDelay", host.MaxCrawlDelay, &limits.MaxCrawlDelay},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(duration.value)
+		if err != nil {
+			return limits, pathError(err, "hosts", name, duration.name)
+		} else if d <= 0 {
+			return limits, pathError(fmt.Errorf("must be strictly positive, get %s", d), "hosts", name, duration.name)
+		}
+		*duration.target = d
+	}
+
+	if limits.MinCrawlDelay > 0 {
+		minCrawlDelay = limits.MinCrawlDelay
+	}
+	if limits.MaxCrawlDelay > 0 {
+		maxCrawlDelay = limits.MaxCrawlDelay
+	}
+	if maxCrawlDelay < minCrawlDelay {
+		path := "maxCrawlDelay"
+		if limits.MaxCrawlDelay == 0 {
+			path = "minCrawlDelay"
+		}
+		return limits, pathError(fmt.Errorf("the max crawl delay must be greater than the min crawl delay"), "hosts", name, path)
+	}
+
+	return limits, nil
+}
+
+/* URL FILTER */
+
+type urlFilter struct {
+	schemes                    []string
+	includeHosts, excludeHosts []*regexp.Regexp
+	includePaths, excludePaths []*regexp.Regexp
+	// Filter by host, if nil use the global paths.
+	hosts map[string]hostFilter
+}
+
+type hostFilter struct {
+	includePaths, excludePaths []*regexp.Regexp
+}
+
+// Return true to strike the URL.
+func (filter *urlFilter) strike(u *url.URL) bool {
+	if !hasString(filter.schemes, u.Scheme) {
+		return true
+	}
+
+	host := u.Hostname()
+	if len(filter.includeHosts) > 0 && !matchAny(filter.includeHosts, host) {
+		return true
+	} else if matchAny(filter.excludeHosts, host) {
+		return true
+	}
+
+	includePaths, excludePaths := filter.includePaths, filter.excludePaths
+	if hf, ok := filter.hosts[host]; ok {
+		includePaths, excludePaths = hf.includePaths, hf.excludePaths
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	if len(includePaths) > 0 && !matchAny(includePaths, path) {
+		return true
+	}
+	return matchAny(excludePaths, path)
+}
+
+// Compile the glob or the regexp (with "re:" prefix) patterns.
+func compilePatterns(patterns []string, path ...any) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		expr := ""
+		if strings.HasPrefix(pattern, "re:") {
+			expr = pattern[3:]
+		} else {
+			expr = globToRegexp(pattern)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, pathError(err, append(path, i)...)
+		}
+		compiled[i] = re
+	}
+	return compiled, nil
+}
+
+// Convert a glob pattern into a full match regular expression. The "*"
+// match any characters and "?" match one character.
+func globToRegexp(glob string) string {
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return expr.String()
+}
+
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+/* LANGUAGE */
+
+// Lower case and replace "_" by "-".
+func normalizeLanguage(lang string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(lang)), "_", "-")
+}
+
+// The lang is accepted if it's in the list, or if its primary sub tag
+// ("fr" of "fr-FR") is in the list.
+func acceptLanguage(languages []string, lang string) bool {
+	primary, _, _ := strings.Cut(lang, "-")
+	for _, accepted := range languages {
+		if accepted == lang || accepted != "" && accepted == primary {
+			return true
+		}
+	}
+	return false
+}
+
+/* ERROR POSITION */
+
+// Create an error with the path elements joined by a dot.
+func pathError(err error, path ...any) error {
+	s := make([]string, len(path))
+	for i, p := range path {
+		s[i] = fmt.Sprint(p)
+	}
+	return &Error{Path: strings.Join(s, "."), Err: err}
+}
+
+// Get the offset of the end of each value, indexed by the path.
+func valueOffsets(data []byte) map[string]int64 {
+	offsets := make(map[string]int64)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		offsets[path] = decoder.InputOffset()
+
+		prefix := path
+		if prefix != "" {
+			prefix += "."
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				if err := walk(prefix + fmt.Sprint(key)); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := walk(prefix + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	walk("")
+
+	return offsets
+}
+
+// Get the path of the first value with the unknown field name, or an empty
+// string.
+func unknownFieldPath(data []byte, name string) string {
+	path, first := "", int64(-1)
+	for p, offset := range valueOffsets(data) {
+		if p != name && !strings.HasSuffix(p, "."+name) {
+			continue
+		} else if knownPath(reflect.TypeOf(File{}), strings.Split(p, ".")) {
+			continue
+		} else if first < 0 || offset < first {
+			path, first = p, offset
+		}
+	}
+	return path
+}
+
+// Return true if the path elements are a JSON field of the type t.
+func knownPath(t reflect.Type, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if strings.EqualFold(name, path[0]) {
+				return knownPath(field.Type, path[1:])
+			}
+		}
+	case reflect.Map:
+		// The key (like a host) can contain dots.
+		if len(path) == 1 {
+			return true
+		}
+		for i := 1; i < len(path); i++ {
+			if knownPath(t.Elem(), path[i:]) {
+				return true
+			}
+		}
+	case reflect.Slice:
+		return knownPath(t.Elem(), path[1:])
+	}
+	return false
+}
+
+// Get the line number (from one) of the offset.
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
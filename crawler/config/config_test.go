@@ -0,0 +1,102 @@
+package crawlconfig
+
+import (
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"testing"
+	"time"
+)
+
+const exampleFile = `{
+	"seeds": ["https://www.uvsq.fr/"],
+	"schemes": ["https"],
+	"includeHosts": ["uvsq.fr", "*.uvsq.fr"],
+	"excludeHosts": ["re:^cas[0-9]*\\.uvsq\\.fr$"],
+	"excludePaths": ["/admin/*", "*.pdf"],
+	"languages": ["", "fr"],
+	"maxGo": 10,
+	"adminAddress": "localhost:8001",
+	"minCrawlDelay": "1s",
+	"speculative": {"disableWWW": true, "maxParentHost": -1},
+	"hosts": {
+		"blog.uvsq.fr": {"weight": 2, "includePaths": ["/2023/*"]},
+		"media.uvsq.fr": {"minCrawlDelay": "2s", "maxLength": 1000}
+	}
+}`
+
+func TestParse(t *testing.T) {
+	records, handler := sloghandlers.NewHandlerRecords(slog.InfoLevel)
+	config, err := Parse(slog.New(handler), "crawl.json", []byte(exampleFile))
+	assert.NoError(t, err)
+
+	assert.Equal(t, common.ParseURLs("https://www.uvsq.fr/"), config.Input)
+	assert.Equal(t, 10, config.MaxGo)
+	assert.Equal(t, "localhost:8001", config.AdminAddress)
+	assert.Equal(t, int64(15_000_000), config.MaxLength)
+	assert.Equal(t, time.Second, config.MinCrawlDelay)
+	assert.Equal(t, time.Second*10, config.MaxCrawlDelay)
+	assert.Equal(t, map[string]float64{"blog.uvsq.fr": 2}, config.HostWeight)
+	assert.Equal(t, map[string]crawler.HostLimits{
+		"media.uvsq.fr": {MinCrawlDelay: time.Second * 2, MaxLength: 1000},
+	}, config.HostLimits)
+	assert.Equal(t, crawler.Speculative{DisableWWW: true, MaxParentHost: -1}, config.Speculative)
+
+	assert.Len(t, config.FilterURL, 1)
+	strike := config.FilterURL[0]
+	assert.False(t, strike(common.ParseURL("https://uvsq.fr/")))
+	assert.False(t, strike(common.ParseURL("https://www.uvsq.fr/news/")))
+	assert.True(t, strike(common.ParseURL("http://www.uvsq.fr/")))
+	assert.True(t, strike(common.ParseURL("https://example.org/")))
+	assert.True(t, strike(common.ParseURL("https://notuvsq.fr/")))
+	assert.True(t, strike(common.ParseURL("https://cas2.uvsq.fr/")))
+	assert.True(t, strike(common.ParseURL("https://www.uvsq.fr/admin/users/1")))
+	assert.True(t, strike(common.ParseURL("https://www.uvsq.fr/files/report.pdf")))
+	assert.False(t, strike(common.ParseURL("https://blog.uvsq.fr/2023/01/post.html")))
+	assert.True(t, strike(common.ParseURL("https://blog.uvsq.fr/2022/01/post.html")))
+
+	assert.Len(t, config.FilterPage, 1)
+	strikePage := config.FilterPage[0]
+	for lang, expected := range map[string]bool{
+		"":        false,
+		"fr":      false,
+		"fr_FR":   false,
+		"fr-incl": false,
+		"en":      true,
+		"frr":     true,
+	} {
+		assert.Equal(t, expected, strikePage(&htmlnode.Root{Meta: htmlnode.Meta{Langage: lang}}), lang)
+	}
+	assert.True(t, strikePage(&htmlnode.Root{Meta: htmlnode.Meta{Langage: "en"}}))
+	assert.ElementsMatch(t, []string{
+		"WARN [crawl.unknown_lang] lang=en",
+		"WARN [crawl.unknown_lang] lang=frr",
+	}, *records)
+}
+
+func TestParseError(t *testing.T) {
+	testError := func(expected, file string) {
+		t.Helper()
+		_, err := Parse(nil, "crawl.json", []byte(file))
+		assert.EqualError(t, err, expected)
+	}
+
+	testError("crawl.json:3: invalid character '}' looking for beginning of object key string", "{\n\t\"seeds\": [],\n}")
+	testError("crawl.json:2: maxGo: json: cannot unmarshal string into Go struct field File.maxGo of type int", "{\n\t\"maxGo\": \"10\"\n}")
+	testError(`crawl.json:2: maxgoo: json: unknown field "maxgoo"`, "{\n\t\"maxgoo\": 10\n}")
+	testError(`crawl.json:3: hosts.www.example.org.wieght: json: unknown field "wieght"`, "{\"maxGo\": 1, \"hosts\": {\n\t\"example.org\": {\"weight\": 1},\n\t\"www.example.org\": {\"wieght\": 1}\n}}")
+	testError(`crawl.json:2: hosts.example.org.minCrawlDelay: the max crawl delay must be greater than the min crawl delay`, "{\"hosts\": {\n\t\"example.org\": {\"minCrawlDelay\": \"1m\"}\n}}")
+	testError(`crawl.json:4: seeds.1: "/index.html" is not an absolute HTTP URL`, "{\"seeds\": [\n\t\"https://example.org/\",\n\n\t\"/index.html\"\n]}")
+	testError(`crawl.json:3: minCrawlDelay: time: invalid duration "one second"`, "{\n\t\"maxGo\": 1,\n\t\"minCrawlDelay\": \"one second\"\n}")
+	testError(`crawl.json:1: maxCrawlDelay: must be greater than minCrawlDelay`, `{"minCrawlDelay": "1m", "maxCrawlDelay": "1s"}`)
+	testError("crawl.json:4: hosts.example.org.excludePaths.0: error parsing regexp: missing closing ): `(`", "{\"hosts\": {\n\t\"example.org\": {\n\t\t\"excludePaths\": [\n\t\t\t\"re:(\"\n\t\t]\n\t}\n}}")
+	testError(`crawl.json:2: hosts.example.org.weight: must be positive, get -1`, "{\"hosts\": {\n\t\"example.org\": {\"weight\": -1}\n}}")
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	assert.Equal(t, `^.*\.uvsq\.fr$`, globToRegexp(`*.uvsq.fr`))
+	assert.Equal(t, `^/page-.\.html$`, globToRegexp(`/page-?.html`))
+}
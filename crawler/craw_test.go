@@ -9,12 +9,14 @@ import (
 	"github.com/HuguesGuilleus/isty-search/crawler/database"
 	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
 	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/metrics"
 	"github.com/HuguesGuilleus/isty-search/sloghandlers"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/exp/slog"
 	"io"
 	"io/fs"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"sort"
 	"strings"
@@ -43,10 +45,13 @@ func TestCrawl(t *testing.T) {
 		FilterPage: []func(*htmlnode.Root) bool{
 			func(page *htmlnode.Root) bool { return page.Meta.Langage != "en" },
 		},
-		MaxLength:    15_000_000,
-		MaxGo:        1,
-		RoundTripper: datatestRoundTripper{},
-		Logger:       logger,
+		MaxLength: 15_000_000,
+		MaxGo:     1,
+		Fetcher: Fetcher{
+			RoundTripper: datatestRoundTripper{},
+			UserAgent:    "IstyTest/1.0",
+		},
+		Logger: logger,
 	})
 	assert.NoError(t, err)
 
@@ -55,12 +60,19 @@ func TestCrawl(t *testing.T) {
 	assert.Equal(t, []string{
 		`INFO [fetch.err] err=FATAL url=https://example.org/err-fatal`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/deep.html`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/dir/`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/dir/subdir/`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/es.html`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/favicon.ico`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/known.html`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/news.html`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/news.rss`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/print.html`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/redirected.html`,
 		`INFO [fetch.ok] status=+200 url=https://example.org/robots.txt`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/sitemap-index.xml`,
+		`INFO [fetch.ok] status=+200 url=https://example.org/sitemap.xml.gz`,
 		`INFO [fetch.ok] status=+308 url=https://example.org/redirection`,
 		`INFO [fetch.ok] status=+404 url=https://example.org/err-404`,
 	}, *records)
@@ -83,26 +95,71 @@ func TestCrawl(t *testing.T) {
 		assert.Equal(t, root.Body.PrintLines(), page.Html.Body.PrintLines())
 	}
 
+	// Test the response
+	home, _, _ := db.GetValue(keys.NewString("https://example.org/"))
+	homeData, _ := fs.ReadFile(testdata, "testdata/index.html")
+	assert.Equal(t, 200, home.Response.Status)
+	assert.Equal(t, "https://example.org/", home.Response.URL.String())
+	assert.Equal(t, len(homeData), home.Response.BodySize)
+	assert.False(t, home.Response.Truncated)
+
+	// Test the favicon
+	favicon, _, err := db.GetValue(FaviconKey("https", "example.org"))
+	assert.NoError(t, err)
+	faviconData, _ := fs.ReadFile(testdata, "testdata/favicon.ico")
+	assert.Equal(t, &Favicon{Type: "image/x-icon", Data: faviconData}, favicon.Favicon)
+
+	// Test the canonical alias
+	assert.Equal(t, keys.NewString("https://example.org/dir/"),
+		db.Redirections()[keys.NewString("https://example.org/print.html")])
+
 	// Test with statistics
+	// Test the URL origins
+	origins, err := db.Origins()
+	assert.NoError(t, err)
+	root := common.ParseURL("https://example.org/")
+	assert.Equal(t, crawldatabase.Origin{}, origins[keys.NewURL(root)])
+	assert.Equal(t, crawldatabase.Origin{Source: root, Depth: 1},
+		origins[keys.NewString("https://example.org/dir/subdir/")])
+	assert.Equal(t, crawldatabase.Origin{Source: root, Depth: 1, Speculative: speculativeParentPath},
+		origins[keys.NewString("https://example.org/dir/")])
+	assert.Equal(t, crawldatabase.Origin{Source: root, Depth: 1, Speculative: speculativeWWW},
+		origins[keys.NewString("https://www.example.org/")])
+	assert.Equal(t, crawldatabase.Origin{Source: common.ParseURL("https://example.org/dir/"), Depth: 2},
+		origins[keys.NewString("https://example.org/print.html")])
+
 	stats := db.Statistics()
 	stats.TotalFileSize = 0
 	stats.FileSize = [crawldatabase.TypeError]int64{}
 	assert.Equal(t, crawldatabase.Statistics{
 		Count: [256]int{
-			crawldatabase.TypeKnow:            1, // the favicon
 			crawldatabase.TypeRedirect:        1,
 			crawldatabase.TypeFileRobots:      1,
-			crawldatabase.TypeFileHTML:        5,
-			crawldatabase.TypeErrorNetwork:    2,
+			crawldatabase.TypeFileHTML:        7,
+			crawldatabase.TypeFileRSS:         1,
+			crawldatabase.TypeFileSitemap:     2,
+			crawldatabase.TypeFileFavicon:     1,
+			crawldatabase.TypeFileHost:        1,
+			crawldatabase.TypeAliasCanonical:  1,
+			crawldatabase.TypeErrorNetwork:    1,
 			crawldatabase.TypeErrorFilterURL:  3, // google(x2)+www.exemple
 			crawldatabase.TypeErrorFilterPage: 1,
-			crawldatabase.TypeErrorRobot:      1,
+			crawldatabase.TypeErrorRobot:      2, // robotBlocked+agentBlocked
+			crawldatabase.TypeErrorNotFound:   1,
 		},
-		Total:      15,
-		TotalFile:  6,
-		TotalError: 7,
+		Total:      23,
+		TotalFile:  13,
+		TotalError: 8,
+		HTTPStatus: [600]int{404: 1},
 	}, stats)
 
+	// Test the metrics
+	w := httptest.NewRecorder()
+	metrics.Default.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "\nisty_crawler_db_entries{type=\"fileHTML\"} 7\n")
+	assert.Contains(t, w.Body.String(), "\nisty_crawler_frontier_urls 0\n")
+	assert.Contains(t, w.Body.String(), "\nisty_crawler_fetch_total{status=\"404\",outcome=\"errorNotFound\"} ")
+
 	// Test the process
 	foundURL := []string{}
 	assert.NoError(t, Process(db,
@@ -113,9 +170,11 @@ func TestCrawl(t *testing.T) {
 	sort.Strings(foundURL)
 	assert.Equal(t, []string{
 		"https://example.org/",
+		"https://example.org/deep.html",
 		"https://example.org/dir/",
 		"https://example.org/dir/subdir/",
 		"https://example.org/known.html",
+		"https://example.org/news.html",
 		"https://example.org/redirected.html",
 	}, foundURL)
 }
@@ -128,6 +187,8 @@ type datatestRoundTripper struct{}
 func (_ datatestRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
 	if request.Method != http.MethodGet {
 		panic("Method not allowed")
+	} else if ua := request.Header.Get("User-Agent"); ua != "IstyTest/1.0" {
+		panic(fmt.Sprintf(`Wrong User-Agent %q, expected "IstyTest/1.0"`, ua))
 	} else if request.Host != "example.org" {
 		panic(fmt.Sprintf(`Wrong host %q, expected "example.org"`, request.Host))
 	}
@@ -191,3 +252,62 @@ func (_ datatestRoundTripper) RoundTrip(request *http.Request) (*http.Response,
 		Request:       request,
 	}, nil
 }
+
+func TestCrawlNestedSitemaps(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return nil, db, nil
+		},
+		Input:     common.ParseURLs("https://example.org/"),
+		MaxLength: 15_000,
+		MaxGo:     1,
+		MaxDepth:  1,
+		Fetcher: Fetcher{RoundTripper: mapRoundTripper{
+			"https://example.org/":              []byte(`<!DOCTYPE html><p>Hello</p>`),
+			"https://example.org/robots.txt":    []byte("Sitemap: https://example.org/index.xml\n"),
+			"https://example.org/index.xml":     []byte(`<?xml version="1.0"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>https://example.org/sub-index.xml</loc></sitemap></sitemapindex>`),
+			"https://example.org/sub-index.xml": []byte(`<?xml version="1.0"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>https://example.org/pages.xml</loc></sitemap></sitemapindex>`),
+			"https://example.org/pages.xml":     []byte(`<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://example.org/deep.html</loc></url></urlset>`),
+			"https://example.org/deep.html":     []byte(`<!DOCTYPE html><p>Deep</p>`),
+		}},
+		Logger: slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	assert.Equal(t, crawldatabase.TypeFileSitemap, db.GetType(keys.NewString("https://example.org/pages.xml")))
+	assert.Equal(t, crawldatabase.TypeFileHTML, db.GetType(keys.NewString("https://example.org/deep.html")))
+	origins, err := db.Origins()
+	assert.NoError(t, err)
+	assert.Equal(t, sitemapDepth, origins[keys.NewString("https://example.org/deep.html")].Depth)
+}
+
+func TestCrawlMaxRedirect(t *testing.T) {
+	refresh := func(to string) []byte {
+		return []byte(`<!DOCTYPE html><meta http-equiv="refresh" content="0; url=` + to + `"><p>Moved</p>`)
+	}
+
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return nil, db, nil
+		},
+		Input:     common.ParseURLs("https://example.org/r0.html"),
+		MaxLength: 15_000,
+		MaxGo:     1,
+		Fetcher: Fetcher{
+			MaxRedirect: 2,
+			RoundTripper: mapRoundTripper{
+				"https://example.org/r0.html": refresh("/r1.html"),
+				"https://example.org/r1.html": refresh("/r2.html"),
+				"https://example.org/r2.html": refresh("/r3.html"),
+				"https://example.org/r3.html": []byte(`<!DOCTYPE html><p>End</p>`),
+			},
+		},
+		Logger: slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	assert.Equal(t, crawldatabase.TypeRedirect, db.GetType(keys.NewString("https://example.org/r0.html")))
+	assert.Equal(t, crawldatabase.TypeRedirect, db.GetType(keys.NewString("https://example.org/r1.html")))
+	assert.Equal(t, crawldatabase.TypeFileHTML, db.GetType(keys.NewString("https://example.org/r2.html")))
+	assert.Equal(t, crawldatabase.TypeNothing, db.GetType(keys.NewString("https://example.org/r3.html")))
+}
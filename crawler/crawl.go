@@ -7,11 +7,16 @@ import (
 	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"golang.org/x/exp/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 )
 
+// The default value of Config.UserAgent.
+const DefaultUserAgent = "IstySearch/0.1"
+
 type Config struct {
 	// The database, DB or the root if DB is nil.
 	// DB crawldatabase.Database[Page]
@@ -36,16 +41,70 @@ type Config struct {
 	// Maximum of crawl goroutine
 	MaxGo int
 
+	// Maximum depth of a page from the seeds (Input). The URL from the
+	// database keep the depth of their origin. Zero for no limit.
+	MaxDepth int
+	// Maximum number of page crawled by host, for this crawl.
+	// Zero for no limit.
+	MaxPagesPerHost int
+	// Weight of a host (default is 1), the key is the host with the port,
+	// for exemple "example.org". The host with a bigger weight are crawled
+	// first.
+	HostWeight map[string]float64
+	// Overrides of the crawl delays and of MaxLength by host, the key is
+	// the host with the port like HostWeight.
+	HostLimits map[string]HostLimits
+
+	// After MaxHostErrors consecutive errors (network, 429 or 5xx) a host
+	// is parked during HostParkDuration: it is not crawled, even after a
+	// restart. Between errors, the delay is the Retry-After header or the
+	// crawl delay doubled for each error.
+	// Default: 5 errors and one hour.
+	MaxHostErrors    int
+	HostParkDuration time.Duration
+
 	// The min and max CrawlDelay.
 	// The used value if determined by the robots.txt.
 	// Must: minCrawlDelay < maxCrawlDelay
 	MinCrawlDelay, MaxCrawlDelay time.Duration
 
+	// HTML pages older than RevisitAge are fetched again, with a
+	// conditional request. Zero to disable it.
+	// The interval of each page adapts between RevisitAge and
+	// RevisitMaxAge (zero for no limit): doubled if the page is unchanged,
+	// halved if it changed.
+	RevisitAge, RevisitMaxAge time.Duration
+
 	// A simple logger to slog the database.
 	Logger *slog.Logger
 
 	// Use to fetch all HTTP ressource.
-	RoundTripper http.RoundTripper
+	Fetcher Fetcher
+
+	// The speculative URLs (parent paths, parent hosts...) added for each
+	// link. The zero value add all.
+	Speculative Speculative
+
+	// If not empty, listen the admin HTTP API on this address (like
+	// "localhost:8001") during the crawl, see fetchContext.adminHandler().
+	// The metrics are at /metrics. The API has no authentication, so an
+	// address that is not loopback is refused.
+	AdminAddress string
+
+	// Crawl only the hosts of one shard: the hosts are shared between
+	// Shards crawls (one by process) with ShardOf(). This crawl is the
+	// shard number Shard, it listens the admin API on the Unix socket
+	// ShardSockets[Shard], and forwards the URLs of the other shards to
+	// their socket. Disabled if Shards < 2.
+	Shard, Shards int
+	ShardSockets  []string
+}
+
+// The limits of one host, see Config.HostLimits. A zero field keeps the
+// global value.
+type HostLimits struct {
+	MinCrawlDelay, MaxCrawlDelay time.Duration
+	MaxLength                    int64
 }
 
 func Crawl(mainContext context.Context, config Config) error {
@@ -54,35 +113,155 @@ func Crawl(mainContext context.Context, config Config) error {
 		return fmt.Errorf("Open the database with base=%q: %w", config.DBbase, err)
 	}
 
-	fetchContext := &fetchContext{
-		db:            db,
-		hosts:         make(map[string]*host),
-		context:       mainContext,
-		maxGo:         config.MaxGo,
-		filterURL:     config.FilterURL,
-		filterPage:    config.FilterPage,
-		roundTripper:  newlogRoundTripper(config.RoundTripper, config.Logger),
-		maxLength:     config.MaxLength,
-		minCrawlDelay: config.MinCrawlDelay,
-		maxCrawlDelay: config.MaxCrawlDelay,
+	if config.Shards > 1 && (config.Shard < 0 || config.Shard >= config.Shards || len(config.ShardSockets) != config.Shards) {
+		return fmt.Errorf("Wrong shard %d of %d shards with %d sockets", config.Shard, config.Shards, len(config.ShardSockets))
+	}
+	if err := CheckShardSockets(config.ShardSockets); err != nil {
+		return err
+	}
+	if config.AdminAddress != "" {
+		if err := checkAdminAddress(config.AdminAddress); err != nil {
+			return err
+		}
+	}
+
+	fetchContext := newFetchContext(mainContext, config, db)
+	fetchContext.registerMetrics()
+
+	urlsRevisit := []*url.URL(nil)
+	if config.RevisitAge > 0 {
+		urlsRevisit, err = db.URLsBefore(crawldatabase.TypeFileHTML, time.Now().Add(-config.RevisitAge))
+		if err != nil {
+			return fmt.Errorf("Get the URLs to revisit: %w", err)
+		}
+	}
+
+	if config.AdminAddress != "" {
+		listener, err := net.Listen("tcp", config.AdminAddress)
+		if err != nil {
+			return fmt.Errorf("Listen the admin API: %w", err)
+		}
+		server := &http.Server{Handler: fetchContext.adminHandler()}
+		go server.Serve(listener)
+		defer server.Close()
+		config.Logger.Info("crawl.admin", "address", listener.Addr().String())
+	}
+
+	defer fetchContext.closeWorkers()
+
+	if fetchContext.shards != nil {
+		socket := config.ShardSockets[config.Shard]
+		os.Remove(socket)
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return fmt.Errorf("Listen the shard socket: %w", err)
+		}
+		// The admin API has no authentication.
+		if err := os.Chmod(socket, 0600); err != nil {
+			listener.Close()
+			return fmt.Errorf("Restrict the shard socket: %w", err)
+		}
+		server := &http.Server{Handler: fetchContext.adminHandler()}
+		go server.Serve(listener)
+		fetchContext.shards.start(mainContext)
+		config.Logger.Info("crawl.shard", "shard", config.Shard, "shards", config.Shards, "socket", socket)
+		// The other shards can forward URLs until the end of the context.
+		defer func() {
+			<-mainContext.Done()
+			server.Close()
+			os.Remove(socket)
+		}()
 	}
-	defer fetchContext.wg.Wait()
 
 	urls4db := make(map[keys.Key]*url.URL, len(config.Input))
 	urls4plan := make(map[keys.Key]*url.URL, len(config.Input))
+	origins := make(map[keys.Key]crawldatabase.Origin, len(config.Input))
 	for _, u := range config.Input {
 		key := keys.NewURL(u)
 		urls4plan[key] = u
 		urls4db[key] = u
+		origins[key] = crawldatabase.Origin{}
 	}
-	fetchContext.db.AddURL(urls4db)
-	fetchContext.planURLs(urls4plan)
+	fetchContext.db.AddURL(urls4db, origins)
+	fetchContext.removeNotDue(urls4plan)
+	fetchContext.shards.forward(urls4plan, nil)
+	fetchContext.planURLs(urls4plan, 0)
 
-	urlsFromDBMap := make(map[keys.Key]*url.URL, len(config.Input))
-	for _, u := range urlsFromDB {
-		urlsFromDBMap[keys.NewURL(u)] = u
+	urlsFromDBMap := make(map[keys.Key]*url.URL, len(urlsFromDB)+len(urlsRevisit))
+	for _, u := range append(urlsFromDB, urlsRevisit...) {
+		if key := keys.NewURL(u); urls4plan[key] == nil {
+			urlsFromDBMap[key] = u
+		}
+	}
+	fetchContext.removeNotDue(urlsFromDBMap)
+	originsFromDB, err := db.Origins()
+	if err != nil {
+		return fmt.Errorf("Get the origins of the URLs: %w", err)
+	}
+	fetchContext.shards.forward(urlsFromDBMap, originsFromDB)
+	// Plan the URLs at the depth of their origin.
+	urlsByDepth := make(map[int]map[keys.Key]*url.URL)
+	for key, u := range urlsFromDBMap {
+		depth := originsFromDB[key].Depth
+		if config.MaxDepth > 0 && depth > config.MaxDepth {
+			continue
+		} else if urlsByDepth[depth] == nil {
+			urlsByDepth[depth] = make(map[keys.Key]*url.URL)
+		}
+		urlsByDepth[depth][key] = u
+	}
+	for depth, urls := range urlsByDepth {
+		fetchContext.planURLs(urls, depth)
 	}
-	fetchContext.planURLs(urlsFromDBMap)
 
 	return nil
 }
+
+// Create the fetch context from the config, with the opened database.
+func newFetchContext(mainContext context.Context, config Config, db *crawldatabase.Database[Page]) *fetchContext {
+	maxHostErrors := config.MaxHostErrors
+	if maxHostErrors <= 0 {
+		maxHostErrors = 5
+	}
+	hostParkDuration := config.HostParkDuration
+	if hostParkDuration <= 0 {
+		hostParkDuration = time.Hour
+	}
+
+	shards := (*shardForwarder)(nil)
+	if config.Shards > 1 {
+		shards = newShardForwarder(config.Logger, config.Shard, config.ShardSockets)
+	}
+
+	ctx := &fetchContext{
+		db:               db,
+		hosts:            make(map[string]*host),
+		hostsPlanned:     make(map[string]int),
+		hostWeight:       config.HostWeight,
+		hostLimits:       config.HostLimits,
+		maxDepth:         config.MaxDepth,
+		maxPagesPerHost:  config.MaxPagesPerHost,
+		parked:           make(map[string]bool),
+		pausedHosts:      make(map[string]bool),
+		inFlight:         make(map[string]*url.URL),
+		sitemapsAdded:    make(map[string]bool),
+		maxHostErrors:    maxHostErrors,
+		hostParkDuration: hostParkDuration,
+		logger:           config.Logger,
+		context:          mainContext,
+		maxGo:            config.MaxGo,
+		filterURL:        config.FilterURL,
+		filterPage:       config.FilterPage,
+		fetcher:          config.Fetcher.withDefault(config.Logger),
+		maxLength:        config.MaxLength,
+		minCrawlDelay:    config.MinCrawlDelay,
+		maxCrawlDelay:    config.MaxCrawlDelay,
+		revisitAge:       config.RevisitAge,
+		revisitMaxAge:    config.RevisitMaxAge,
+		fingerprints:     newFingerprintIndex(db),
+		speculative:      config.Speculative,
+		shards:           shards,
+	}
+	ctx.workerEnd.L = &ctx.hostsMutex
+	return ctx
+}
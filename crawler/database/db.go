@@ -12,11 +12,13 @@ import (
 	"golang.org/x/exp/slog"
 	"io"
 	"io/fs"
+	"math"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -65,6 +67,18 @@ func OpenWithKnow[T any](logger *slog.Logger, base string, logStatistics bool) (
 	return open[T](logger, base, logStatistics, []byte{TypeKnow})
 }
 
+// Open the DB, and return all know URL and URL with a temporary error
+// (network, DNS, timeout and server), to retry them.
+func OpenWithRetry[T any](logger *slog.Logger, base string, logStatistics bool) ([]*url.URL, *Database[T], error) {
+	return open[T](logger, base, logStatistics, []byte{
+		TypeKnow,
+		TypeErrorNetwork,
+		TypeErrorDNS,
+		TypeErrorTimeout,
+		TypeErrorServer,
+	})
+}
+
 // Open the database but return no url.
 func Open[T any](logger *slog.Logger, base string, logStatistics bool) ([]*url.URL, *Database[T], error) {
 	return open[T](logger, base, logStatistics, nil)
@@ -87,7 +101,7 @@ func open[T any](logger *slog.Logger, base string, logStatistics bool, acceptedT
 	if err != nil {
 		return nil, nil, err
 	}
-	urlsFile, err := openFile(logger, base, filenameURLS, os.O_WRONLY|os.O_APPEND)
+	urlsFile, err := openFile(logger, base, filenameURLS, os.O_RDWR|os.O_APPEND)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -182,17 +196,21 @@ func (db *Database[_]) CountHTML() int {
 	return db.Statistics().Count[TypeFileHTML]
 }
 
-// Add unknwon url.
+// Add unknwon url, with their origin (origins can be nil).
 //
 // If the URL is known, is deleted of urls, else is saved in DB files.
 // Error are logged and returned.
-func (db *Database[_]) AddURL(urls map[keys.Key]*url.URL) error {
+func (db *Database[_]) AddURL(urls map[keys.Key]*url.URL, origins map[keys.Key]Origin) error {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
 	for key, u := range urls {
 		if db.mapMeta[key].Type == TypeNothing {
-			if _, err := db.urlsFile.WriteString(u.String() + "\n"); err != nil {
+			origin := (*Origin)(nil)
+			if o, ok := origins[key]; ok {
+				origin = &o
+			}
+			if _, err := db.urlsFile.WriteString(formatURLLine(u, origin) + "\n"); err != nil {
 				f := filepath.Join(db.base, filenameURLS)
 				db.logger.Error("db.err", err, "file", f)
 				return fmt.Errorf("DB Write in %q: %w", f, err)
@@ -213,6 +231,83 @@ func (db *Database[_]) AddURL(urls map[keys.Key]*url.URL) error {
 	return nil
 }
 
+// Return all URLs of type t stored before the instant.
+func (db *Database[_]) URLsBefore(t byte, before time.Time) ([]*url.URL, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	data, err := io.ReadAll(io.NewSectionReader(db.urlsFile, 0, math.MaxInt64))
+	if err != nil {
+		f := filepath.Join(db.base, filenameURLS)
+		db.logger.Error("db.err", err, "file", f)
+		return nil, fmt.Errorf("DB read %q: %w", f, err)
+	}
+
+	limit := before.Unix()
+	urls := make([]*url.URL, 0)
+	for _, s := range strings.Split(string(data), "\n") {
+		s, _ = parseURLLine(s)
+		if meta := db.mapMeta[keys.NewString(s)]; meta.Type != t || meta.Time > limit {
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+// Return the origin of all URLs saved with an origin.
+func (db *Database[_]) Origins() (map[keys.Key]Origin, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	data, err := io.ReadAll(io.NewSectionReader(db.urlsFile, 0, math.MaxInt64))
+	if err != nil {
+		f := filepath.Join(db.base, filenameURLS)
+		db.logger.Error("db.err", err, "file", f)
+		return nil, fmt.Errorf("DB read %q: %w", f, err)
+	}
+
+	origins := make(map[keys.Key]Origin)
+	for _, line := range strings.Split(string(data), "\n") {
+		if s, origin := parseURLLine(line); origin != nil {
+			origins[keys.NewString(s)] = *origin
+		}
+	}
+
+	return origins, nil
+}
+
+// Return the URL of all keys saved in the urls file.
+func (db *Database[_]) URLs() (map[keys.Key]*url.URL, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	data, err := io.ReadAll(io.NewSectionReader(db.urlsFile, 0, math.MaxInt64))
+	if err != nil {
+		f := filepath.Join(db.base, filenameURLS)
+		db.logger.Error("db.err", err, "file", f)
+		return nil, fmt.Errorf("DB read %q: %w", f, err)
+	}
+
+	urls := make(map[keys.Key]*url.URL)
+	for _, line := range strings.Split(string(data), "\n") {
+		s, _ := parseURLLine(line)
+		if s == "" {
+			continue
+		}
+		if u, err := url.Parse(s); err == nil {
+			urls[keys.NewString(s)] = u
+		}
+	}
+
+	return urls, nil
+}
+
 // Get the value from the DB.
 // If the value if not a file, return NotFile.
 // If the value do not exist, return NotExist.
@@ -221,7 +316,7 @@ func (db *Database[T]) GetValue(key keys.Key) (*T, time.Time, error) {
 	meta := db.getMetavalue(key)
 	if meta.Type == TypeNothing {
 		return nil, time.Time{}, NotExist
-	} else if meta.Type < TypeFile || meta.Type >= TypeError {
+	} else if meta.Type < TypeFile || meta.Type >= TypeAlias {
 		return nil, time.Time{}, NotFile
 	}
 
@@ -271,6 +366,11 @@ func (db *Database[T]) readValue(key keys.Key, meta metavalue) (*T, error) {
 	return value, nil
 }
 
+// Get the type of the key, TypeNothing if the key is unknown.
+func (db *Database[_]) GetType(key keys.Key) byte {
+	return db.getMetavalue(key).Type
+}
+
 func (db *Database[_]) getMetavalue(key keys.Key) metavalue {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
@@ -280,7 +380,7 @@ func (db *Database[_]) getMetavalue(key keys.Key) metavalue {
 // Set the value to the DB, overwrite previous value.
 // t must be a type of a regular file.
 func (db *Database[T]) SetValue(key keys.Key, value *T, t byte) error {
-	if t < TypeFile || t >= TypeError {
+	if t < TypeFile || t >= TypeAlias {
 		return fmt.Errorf("DB.SetValue(key=%s): The type %d is not for a file", key, t)
 	} else if value == nil {
 		return fmt.Errorf("DB.SetValue(key=%s): the value is nil", key)
@@ -355,6 +455,31 @@ func (db *Database[_]) SetSimple(key keys.Key, t byte) error {
 	return nil
 }
 
+// Set an error type with the HTTP status of the response, zero if there
+// is no response. The status is saved only for type >= TypeErrorHTTP.
+func (db *Database[_]) SetError(key keys.Key, t byte, status int) error {
+	if t < TypeError {
+		return fmt.Errorf("Db.SetError(key=%s, type=%d) use a type that is not an error", key, t)
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	meta := metavalue{
+		Type: t,
+		Time: time.Now().Unix(),
+	}
+	if t >= TypeErrorHTTP {
+		meta.Status = uint16(status)
+	}
+	if err := db.setmeta(key, meta); err != nil {
+		return fmt.Errorf("db.SetError(key=%s) %w", key, err)
+	}
+	db.mapMeta[key] = meta
+
+	return nil
+}
+
 // Set the redirection.
 func (db *Database[_]) SetRedirect(key, destination keys.Key) error {
 	db.mutex.Lock()
@@ -373,6 +498,71 @@ func (db *Database[_]) SetRedirect(key, destination keys.Key) error {
 	return nil
 }
 
+// Set an alias of type t (TypeAlias <= t < TypeError) to the destination.
+func (db *Database[_]) SetAlias(key, destination keys.Key, t byte) error {
+	if t < TypeAlias || t >= TypeError {
+		return fmt.Errorf("Db.SetAlias(key=%s, type=%d) use a type that is not an alias", key, t)
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	meta := metavalue{
+		Type: t,
+		Time: time.Now().Unix(),
+		Hash: destination,
+	}
+	if err := db.setmeta(key, meta); err != nil {
+		return fmt.Errorf("SetAlias(key=%s) %w", key, err)
+	}
+	db.mapMeta[key] = meta
+
+	return nil
+}
+
+// One entry of the database, see Database.Entries().
+type Entry struct {
+	Key  keys.Key
+	Type byte
+	// The instant of the store.
+	Time time.Time
+	// The HTTP status, only for type >= TypeErrorHTTP.
+	Status int
+	// The destination of a redirection or an alias.
+	Destination keys.Key
+}
+
+// Return all entries except the known URLs, sorted by store instant.
+func (db *Database[_]) Entries() []Entry {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	entries := make([]Entry, 0, len(db.mapMeta))
+	for key, meta := range db.mapMeta {
+		if meta.Type == TypeKnow {
+			continue
+		}
+		entry := Entry{
+			Key:    key,
+			Type:   meta.Type,
+			Time:   time.Unix(meta.Time, 0),
+			Status: int(meta.Status),
+		}
+		if isRedirectOrAlias(meta.Type) {
+			entry.Destination = meta.Hash
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if a, b := entries[i].Time, entries[j].Time; !a.Equal(b) {
+			return a.Before(b)
+		}
+		return bytes.Compare(entries[i].Key[:], entries[j].Key[:]) < 0
+	})
+
+	return entries
+}
+
 type keyvalue[T any] struct {
 	k keys.Key
 	v *T
@@ -434,7 +624,7 @@ func (db *Database[T]) ForHTML(f func(keys.Key, *T)) (returnErr error) {
 	return
 }
 
-// Return all redictions to valid file.
+// Return all redictions and alias to valid file.
 // If r1 -> r2 -> r3 -> p, the map contain:
 //   - m[r1] = p
 //   - m[r2] = p
@@ -447,21 +637,22 @@ func (db *Database[_]) Redirections() map[keys.Key]keys.Key {
 
 	m := make(map[keys.Key]keys.Key)
 	for key, meta := range db.mapMeta {
-		if meta.Type != TypeRedirect {
+		if !isRedirectOrAlias(meta.Type) {
 			continue
 		}
 		dest := meta.Hash
+	chain:
 		for i := 0; i < 10; i++ {
 			newMeta := db.mapMeta[dest]
 			t := newMeta.Type
 			switch {
-			case t == TypeRedirect:
+			case isRedirectOrAlias(t):
 				dest = newMeta.Hash
-			case TypeFile <= t && t < TypeError:
+			case TypeFile <= t && t < TypeAlias:
 				m[key] = dest
-				break
+				break chain
 			default:
-				break
+				break chain
 			}
 		}
 	}
@@ -469,6 +660,10 @@ func (db *Database[_]) Redirections() map[keys.Key]keys.Key {
 	return m
 }
 
+func isRedirectOrAlias(t byte) bool {
+	return t == TypeRedirect || TypeAlias <= t && t < TypeError
+}
+
 func (db *Database[_]) setmeta(key keys.Key, meta metavalue) error {
 	if err := writeElasticMetavalue(key, meta, db.metaFile); err != nil {
 		db.logerror("write.meta", key, err)
@@ -36,10 +36,14 @@ func (f *memFile) Close() error {
 	return nil
 }
 func (f *memFile) ReadAt(p []byte, off int64) (n int, err error) {
-	if int(off)+len(p) > len(*f) {
-		return 0, io.ErrShortBuffer
+	if off >= int64(len(*f)) {
+		return 0, io.EOF
 	}
-	return copy(p, (*f)[int(off):]), nil
+	n = copy(p, (*f)[int(off):])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
 }
 func (f *memFile) WriteString(s string) (int, error) { return f.Write([]byte(s)) }
 func (f *memFile) Write(p []byte) (int, error) {
@@ -33,11 +33,11 @@ func TestDBFile(t *testing.T) {
 
 	// First AddURL
 	urlsMap, originLen := getURLS()
-	assert.NoError(t, db.AddURL(urlsMap))
+	assert.NoError(t, db.AddURL(urlsMap, nil))
 	assert.Len(t, urlsMap, originLen)
 
 	// Second AddURL
-	assert.NoError(t, db.AddURL(urlsMap))
+	assert.NoError(t, db.AddURL(urlsMap, nil))
 	assert.Empty(t, urlsMap)
 
 	// Wrong set
@@ -96,7 +96,7 @@ func TestDBFile(t *testing.T) {
 	}, common.URL2String(urls))
 
 	urlsMap, _ = getURLS()
-	assert.NoError(t, db.AddURL(urlsMap))
+	assert.NoError(t, db.AddURL(urlsMap, nil))
 	assert.Empty(t, urlsMap)
 
 	// Get
@@ -123,10 +123,14 @@ func TestDBFile(t *testing.T) {
 	assert.NoError(t, db.SetValue(kt, &http.Cookie{}, TypeFile))
 	assert.NoError(t, db.SetRedirect(koo, ko))
 	assert.NoError(t, db.SetRedirect(keys.NewString("2null"), keys.NewString("null")))
-	// the chain koo -> ko -> kt
+	kalias := keys.NewString("alias")
+	assert.NoError(t, db.SetAlias(kalias, koo, TypeAliasCanonical))
+	assert.Error(t, db.SetAlias(kalias, koo, TypeRedirect))
+	// the chain alias -> koo -> ko -> kt
 	assert.Equal(t, map[keys.Key]keys.Key{
-		koo: kt,
-		ko:  kt,
+		kalias: kt,
+		koo:    kt,
+		ko:     kt,
 	}, db.Redirections())
 
 	// Reopen without urls.
@@ -208,11 +212,11 @@ func TestDBMemory(t *testing.T) {
 
 	// First AddURL
 	urlsMap, originLen := getURLS()
-	assert.NoError(t, db.AddURL(urlsMap))
+	assert.NoError(t, db.AddURL(urlsMap, nil))
 	assert.Len(t, urlsMap, originLen)
 
 	// Second AddURL
-	assert.NoError(t, db.AddURL(urlsMap))
+	assert.NoError(t, db.AddURL(urlsMap, nil))
 	assert.Empty(t, urlsMap)
 
 	// Wrong set
@@ -252,6 +256,17 @@ func TestDBMemory(t *testing.T) {
 	assert.NoError(t, db.SetSimple(kd, TypeNothing))
 	assert.Zero(t, db.mapMeta[kd])
 
+	// Set error
+	ke := keys.NewString("error")
+	assert.Error(t, db.SetError(ke, TypeFileHTML, 0))
+	assert.NoError(t, db.SetError(ke, TypeErrorGone, 410))
+	meta = db.mapMeta[ke]
+	assert.NotZero(t, meta.Time)
+	meta.Time = 0
+	assert.Equal(t, metavalue{Type: TypeErrorGone, Status: 410}, meta)
+	assert.NoError(t, db.SetError(ke, TypeErrorDNS, 410))
+	assert.Zero(t, db.mapMeta[ke].Status)
+
 	// Redirection
 	ko := keys.NewString("origin")
 	kt := keys.NewString("target")
@@ -261,6 +276,39 @@ func TestDBMemory(t *testing.T) {
 	meta.Time = 0
 	assert.Equal(t, metavalue{Type: TypeRedirect, Hash: kt}, meta)
 
+	// URLs before
+	kg := keys.NewString("https://google.com")
+	assert.NoError(t, db.SetValue(kg, &http.Cookie{}, TypeFileHTML))
+	urls, err = db.URLsBefore(TypeFileHTML, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://google.com"}, common.URL2String(urls))
+	urls, err = db.URLsBefore(TypeFileHTML, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+
+	// URLs
+	urlsByKey, err := db.URLs()
+	assert.NoError(t, err)
+	assert.Len(t, urlsByKey, 2)
+	assert.Equal(t, "https://www.google.com", urlsByKey[keys.NewString("https://www.google.com")].String())
+
+	// Entries
+	entries := db.Entries()
+	assert.Len(t, entries, 7)
+	for _, entry := range entries {
+		switch entry.Key {
+		case ko:
+			assert.Equal(t, TypeRedirect, entry.Type)
+			assert.Equal(t, kt, entry.Destination)
+		case ke:
+			assert.Equal(t, TypeErrorDNS, entry.Type)
+		case kg:
+			assert.Equal(t, TypeFileHTML, entry.Type)
+			assert.Zero(t, entry.Destination)
+		}
+		assert.False(t, entry.Time.IsZero())
+	}
+
 	assert.Nil(t, *records)
 }
 
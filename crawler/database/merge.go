@@ -0,0 +1,105 @@
+package crawldatabase
+
+import (
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"golang.org/x/exp/slog"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Merge the databases sources into the database dst, created if it does
+// not exist. It's used to join the databases of a sharded crawl. The URLs
+// are added with their origin. For each key, the value of a fetched URL
+// (a file, an error or an alias) replaces a known URL, else the newest
+// value is kept. The databases must not be opened.
+func Merge[T any](logger *slog.Logger, dst string, sources ...string) error {
+	for _, source := range sources {
+		if _, err := os.Stat(filepath.Join(source, filenameMeta)); err != nil {
+			return fmt.Errorf("Merge source %q: %w", source, err)
+		}
+	}
+
+	_, db, err := open[T](logger, dst, false, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, source := range sources {
+		_, src, err := open[T](logger, source, false, nil)
+		if err != nil {
+			return err
+		}
+		err = db.merge(src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("Merge %q into %q: %w", source, dst, err)
+		}
+		logger.Info("db.merge", "source", source, "dst", dst)
+	}
+
+	return nil
+}
+
+// Merge the URLs and the values of src into db.
+func (db *Database[T]) merge(src *Database[T]) error {
+	data, err := io.ReadAll(io.NewSectionReader(src.urlsFile, 0, math.MaxInt64))
+	if err != nil {
+		return fmt.Errorf("read %q: %w", filepath.Join(src.base, filenameURLS), err)
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for _, line := range strings.Split(string(data), "\n") {
+		s, _ := parseURLLine(line)
+		if s == "" {
+			continue
+		}
+		key := keys.NewString(s)
+		if db.mapMeta[key].Type != TypeNothing {
+			continue
+		}
+		if _, err := db.urlsFile.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("write %q: %w", filepath.Join(db.base, filenameURLS), err)
+		}
+		meta := metavalue{Type: TypeKnow}
+		if err := db.setmeta(key, meta); err != nil {
+			return err
+		}
+		db.mapMeta[key] = meta
+	}
+
+	for key, meta := range src.mapMeta {
+		current := db.mapMeta[key]
+		if meta.Type == TypeKnow || current.Type != TypeNothing && current.Type != TypeKnow && current.Time >= meta.Time {
+			continue
+		}
+
+		if TypeFile <= meta.Type && meta.Type < TypeAlias {
+			chunk := make([]byte, meta.Length)
+			if _, err := src.dataFile.ReadAt(chunk, meta.Position); err != nil {
+				src.logerror("merge.read", key, err)
+				return fmt.Errorf("read the value of key=%s: %w", key, err)
+			}
+			n, err := db.dataFile.Write(chunk)
+			if err != nil {
+				db.logerror("merge.write", key, err)
+				return fmt.Errorf("write the value of key=%s: %w", key, err)
+			}
+			meta.Position = db.position
+			db.position += int64(n)
+		}
+
+		if err := db.setmeta(key, meta); err != nil {
+			return err
+		}
+		db.mapMeta[key] = meta
+	}
+
+	return nil
+}
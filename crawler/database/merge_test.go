@@ -0,0 +1,69 @@
+package crawldatabase
+
+import (
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	_, handler := sloghandlers.NewHandlerRecords(slog.InfoLevel)
+	logger := slog.New(handler)
+	dir := t.TempDir()
+	baseA, baseB, dst := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "dst")
+
+	uA, _ := url.Parse("https://a.example.org/")
+	uB, _ := url.Parse("https://b.example.org/")
+	uC, _ := url.Parse("https://c.example.org/")
+	kA, kB, kC := keys.NewURL(uA), keys.NewURL(uB), keys.NewURL(uC)
+
+	// Shard A: fetch uA, know uB and uC.
+	_, db, err := Open[http.Cookie](logger, baseA, false)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{kA: uA}, nil))
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{kB: uB, kC: uC}, map[keys.Key]Origin{
+		kB: {Source: uA, Depth: 1},
+		kC: {Source: uA, Depth: 1},
+	}))
+	assert.NoError(t, db.SetValue(kA, &http.Cookie{Name: "a"}, TypeFileHTML))
+	assert.NoError(t, db.SetValue(kC, &http.Cookie{Name: "old c"}, TypeFileHTML))
+	meta := db.mapMeta[kC]
+	meta.Time -= 10
+	assert.NoError(t, db.setmeta(kC, meta))
+	db.Close()
+
+	// Shard B: fetch uB and uC.
+	_, db, err = Open[http.Cookie](logger, baseB, false)
+	assert.NoError(t, err)
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{kB: uB, kC: uC}, nil))
+	assert.NoError(t, db.SetError(kB, TypeErrorNotFound, http.StatusNotFound))
+	assert.NoError(t, db.SetValue(kC, &http.Cookie{Name: "c"}, TypeFileHTML))
+	db.Close()
+
+	assert.Error(t, Merge[http.Cookie](logger, dst, baseA, filepath.Join(dir, "nothing")))
+	assert.NoError(t, Merge[http.Cookie](logger, dst, baseA, baseB))
+
+	_, db, err = Open[http.Cookie](logger, dst, false)
+	assert.NoError(t, err)
+
+	value, _, err := db.GetValue(kA)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", value.Name)
+	value, _, err = db.GetValue(kC)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", value.Name)
+	assert.Equal(t, TypeErrorNotFound, db.GetType(kB))
+	assert.Equal(t, uint16(http.StatusNotFound), db.mapMeta[kB].Status)
+
+	urls, err := db.URLs()
+	assert.NoError(t, err)
+	assert.Equal(t, map[keys.Key]*url.URL{kA: uA, kB: uB, kC: uC}, urls)
+	origins, err := db.Origins()
+	assert.NoError(t, err)
+	assert.Equal(t, Origin{Source: uA, Depth: 1}, origins[kB])
+}
@@ -16,6 +16,12 @@ const (
 	TypeFileRSS     byte = 5
 	TypeFileSitemap byte = 6
 	TypeFileFavicon byte = 7
+	TypeFileHost    byte = 8
+
+	// An alias to an other key, saved like a redirection.
+	TypeAlias          byte = 64
+	TypeAliasCanonical byte = 64 // <link rel=canonical> or Link header
+	TypeAliasDuplicate byte = 65 // Near-duplicate of an other page
 
 	TypeError           byte = 128
 	TypeErrorNetwork    byte = 128
@@ -24,8 +30,38 @@ const (
 	TypeErrorFilterPage byte = 131
 	TypeErrorRobot      byte = 132
 	TypeErrorNoIndex    byte = 133
+	TypeErrorDNS        byte = 134
+	TypeErrorTimeout    byte = 135
+	TypeErrorTLS        byte = 136
+	TypeErrorTooLarge   byte = 137
+	// No index from the X-Robots-Tag header or from <meta name=istysearch>,
+	// TypeErrorNoIndex is for <meta name=robots>.
+	TypeErrorNoIndexHeader byte = 138
+	TypeErrorNoIndexAgent  byte = 139
+
+	// Error from a HTTP response, the status is saved in the metavalue.
+	TypeErrorHTTP     byte = 192
+	TypeErrorNotFound byte = 192 // 404
+	TypeErrorGone     byte = 193 // 410
+	TypeErrorClient   byte = 194 // Other 4xx
+	// 5xx, 408 and 429: the server can respond later.
+	TypeErrorServer byte = 195
 )
 
+// Get the error type from a HTTP status.
+func HTTPErrorType(status int) byte {
+	switch {
+	case status == 404:
+		return TypeErrorNotFound
+	case status == 410:
+		return TypeErrorGone
+	case status == 408 || status == 429 || status >= 500:
+		return TypeErrorServer
+	default:
+		return TypeErrorClient
+	}
+}
+
 // The maximum length of the key and the metavalue.
 const keyMetavalueLen = 72
 
@@ -34,13 +70,15 @@ type keymetavalue struct {
 	meta metavalue
 }
 
-// The meta value, different type: nothing | known | redirect | file | error
+// The meta value, different type: nothing | known | redirect | file | alias | error
 type metavalue struct {
 	Type     byte
 	Time     int64
 	Hash     keys.Key
 	Position int64
 	Length   int32
+	// The HTTP status, only for type >= TypeErrorHTTP.
+	Status uint16
 }
 
 func writeElasticMetavalue(key keys.Key, meta metavalue, w io.Writer) error {
@@ -62,13 +100,18 @@ func writeElasticMetavalue(key keys.Key, meta metavalue, w io.Writer) error {
 	bytes[38] = byte(meta.Time >> 8)
 	bytes[39] = byte(meta.Time)
 
-	if meta.Type >= TypeError {
+	if meta.Type >= TypeErrorHTTP {
+		bytes[40] = byte(meta.Status >> 8)
+		bytes[41] = byte(meta.Status)
+		_, err := w.Write(bytes[:42])
+		return err
+	} else if meta.Type >= TypeError {
 		_, err := w.Write(bytes[:40])
 		return err
 	}
 
-	switch meta.Type {
-	case TypeRedirect:
+	switch {
+	case meta.Type == TypeRedirect, meta.Type >= TypeAlias:
 		copy(bytes[40:], meta.Hash[:])
 	default: // file
 		bytes[40] = byte(meta.Position >> 56)
@@ -124,7 +167,15 @@ func loadElasticMetavalue(bytes []byte) map[keys.Key]metavalue {
 			int64(bytes[i+38])<<8 |
 			int64(bytes[i+39])
 
-		if meta.Type >= TypeError {
+		if meta.Type >= TypeErrorHTTP {
+			if i+41 >= len(bytes) {
+				break
+			}
+			meta.Status = uint16(bytes[i+40])<<8 | uint16(bytes[i+41])
+			mapMeta[key] = meta
+			i += 42
+			continue
+		} else if meta.Type >= TypeError {
 			mapMeta[key] = meta
 			i += 40
 			continue
@@ -134,11 +185,11 @@ func loadElasticMetavalue(bytes []byte) map[keys.Key]metavalue {
 			break
 		}
 
-		switch meta.Type {
-		case TypeRedirect:
+		switch {
+		case meta.Type == TypeRedirect, meta.Type >= TypeAlias:
 			copy(meta.Hash[:], bytes[i+40:])
 		default:
-			if meta.Type < TypeError { // It's a file
+			if meta.Type < TypeAlias { // It's a file
 				meta.Position = 0 |
 					int64(bytes[i+40])<<56 |
 					int64(bytes[i+41])<<48 |
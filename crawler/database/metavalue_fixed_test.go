@@ -24,7 +24,13 @@ func writeFixedMetavalue(key keys.Key, meta metavalue, w io.Writer) error {
 	case TypeRedirect:
 		copy(bytes[40:], meta.Hash[:])
 	default:
-		if meta.Type < TypeError { // file type
+		if meta.Type >= TypeErrorHTTP {
+			bytes[40] = byte(meta.Status >> 8)
+			bytes[41] = byte(meta.Status)
+		} else if meta.Type >= TypeError {
+		} else if meta.Type >= TypeAlias {
+			copy(bytes[40:], meta.Hash[:])
+		} else { // file type
 			bytes[40] = byte(meta.Position >> 56)
 			bytes[41] = byte(meta.Position >> 48)
 			bytes[42] = byte(meta.Position >> 40)
@@ -144,7 +150,12 @@ func loadFixedMetavalue(bytes []byte) map[keys.Key]metavalue {
 		case TypeRedirect:
 			copy(meta.Hash[:], bytes[i+keys.Len+8:])
 		default:
-			if meta.Type < TypeError { // It's a file
+			if meta.Type >= TypeErrorHTTP {
+				meta.Status = uint16(bytes[i+keys.Len+8])<<8 | uint16(bytes[i+keys.Len+9])
+			} else if meta.Type >= TypeError {
+			} else if meta.Type >= TypeAlias {
+				copy(meta.Hash[:], bytes[i+keys.Len+8:])
+			} else { // It's a file
 				meta.Position = 0 |
 					int64(bytes[i+keys.Len+8])<<56 |
 					int64(bytes[i+keys.Len+9])<<48 |
@@ -49,6 +49,19 @@ func TestWriteElasticMetavalue(t *testing.T) {
 		0xd0, 0xe1, 0x96, 0xa0, 0xc2, 0x5d, 0x35, 0xdd, 0xa, 0x84, 0x59, 0x3c, 0xba, 0xe0, 0xf3, 0x83, 0x33, 0xaa, 0x58, 0x52, 0x99, 0x36, 0x44, 0x4e, 0xa2, 0x64, 0x53, 0xea, 0xb2, 0x8d, 0xfc, 0x86,
 	})
 
+	testWriteMetavalue("alias", metavalue{
+		Type: TypeAliasCanonical,
+		Time: 0x00_0000_6399_c7d4,
+		Hash: keys.NewURL(googleRootURL),
+	}, []byte{
+		// Type
+		64,
+		// Time
+		0, 0, 0, 0x63, 0x99, 0xc7, 0xd4,
+		// URL
+		0xd0, 0xe1, 0x96, 0xa0, 0xc2, 0x5d, 0x35, 0xdd, 0xa, 0x84, 0x59, 0x3c, 0xba, 0xe0, 0xf3, 0x83, 0x33, 0xaa, 0x58, 0x52, 0x99, 0x36, 0x44, 0x4e, 0xa2, 0x64, 0x53, 0xea, 0xb2, 0x8d, 0xfc, 0x86,
+	})
+
 	testWriteMetavalue("file", metavalue{
 		Type:     TypeFileHTML,
 		Time:     0x00_0000_6399_c7d4,
@@ -79,6 +92,19 @@ func TestWriteElasticMetavalue(t *testing.T) {
 		// Time
 		0, 0, 0, 0x63, 0x99, 0xc7, 0xd4,
 	})
+
+	testWriteMetavalue("http error", metavalue{
+		Type:   TypeErrorNotFound,
+		Time:   0x00_0000_6399_c7d4,
+		Status: 404,
+	}, []byte{
+		// Type
+		192,
+		// Time
+		0, 0, 0, 0x63, 0x99, 0xc7, 0xd4,
+		// Status
+		0x01, 0x94,
+	})
 }
 
 func testLoaderMetavalue(t *testing.T, writer func(keys.Key, metavalue, io.Writer) error, loader func([]byte) map[keys.Key]metavalue) {
@@ -94,6 +120,11 @@ func testLoaderMetavalue(t *testing.T, writer func(keys.Key, metavalue, io.Write
 			Time: 1671022548,
 			Hash: keys.NewURL(googleRootURL),
 		},
+		metavalue{
+			Type: TypeAliasCanonical,
+			Time: 1671022548,
+			Hash: keys.NewURL(googleHowURL),
+		},
 		metavalue{
 			Type:     TypeFileHTML,
 			Time:     1671022548,
@@ -105,6 +136,11 @@ func testLoaderMetavalue(t *testing.T, writer func(keys.Key, metavalue, io.Write
 			Type: TypeErrorNetwork,
 			Time: 1671022548,
 		},
+		metavalue{
+			Type:   TypeErrorServer,
+			Time:   1671022548,
+			Status: 503,
+		},
 	}
 
 	expectedMetavalue := make(map[keys.Key]metavalue, len(metavalueOrigin))
@@ -3,6 +3,7 @@ package crawldatabase
 import (
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"golang.org/x/exp/slog"
+	"strconv"
 )
 
 // All statistics of a database.
@@ -20,6 +21,48 @@ type Statistics struct {
 
 	// Sum of compressed chunck of data
 	TotalFileSize int64
+
+	// Number of HTTP error indexed by the status.
+	HTTPStatus [600]int
+}
+
+// The name of each type, used in the logs and the metrics.
+var typeNames = [...]string{
+	TypeKnow:               "know",
+	TypeRedirect:           "redirect",
+	TypeFileRobots:         "fileRobots",
+	TypeFileHTML:           "fileHTML",
+	TypeFileRSS:            "fileRSS",
+	TypeFileSitemap:        "fileSitemap",
+	TypeFileFavicon:        "fileFavicon",
+	TypeFileHost:           "fileHost",
+	TypeAliasCanonical:     "aliasCanonical",
+	TypeAliasDuplicate:     "aliasDuplicate",
+	TypeErrorNetwork:       "errorNetwork",
+	TypeErrorParsing:       "errorParsing",
+	TypeErrorFilterURL:     "errorFilterURL",
+	TypeErrorFilterPage:    "errorFilterPage",
+	TypeErrorRobot:         "errorRobot",
+	TypeErrorNoIndex:       "errorNoIndex",
+	TypeErrorDNS:           "errorDNS",
+	TypeErrorTimeout:       "errorTimeout",
+	TypeErrorTLS:           "errorTLS",
+	TypeErrorTooLarge:      "errorTooLarge",
+	TypeErrorNoIndexHeader: "errorNoIndexHeader",
+	TypeErrorNoIndexAgent:  "errorNoIndexAgent",
+	TypeErrorNotFound:      "errorNotFound",
+	TypeErrorGone:          "errorGone",
+	TypeErrorClient:        "errorClient",
+	TypeErrorServer:        "errorServer",
+}
+
+// Get the name of the type, like "fileHTML", or the number if the type has
+// no name.
+func TypeName(t byte) string {
+	if int(t) < len(typeNames) && typeNames[t] != "" {
+		return typeNames[t]
+	}
+	return strconv.Itoa(int(t))
 }
 
 // Get the statistics from the metavalue map.
@@ -28,12 +71,14 @@ func getStatistics(m map[keys.Key]metavalue) (stats Statistics) {
 
 	for _, meta := range m {
 		stats.Count[meta.Type]++
-		if t := meta.Type; TypeFile <= t && t < TypeError {
+		if t := meta.Type; TypeFile <= t && t < TypeAlias {
 			stats.FileSize[t] += int64(meta.Length)
+		} else if t >= TypeErrorHTTP && int(meta.Status) < len(stats.HTTPStatus) {
+			stats.HTTPStatus[meta.Status]++
 		}
 	}
 
-	for _, n := range stats.Count[TypeFile:TypeError] {
+	for _, n := range stats.Count[TypeFile:TypeAlias] {
 		stats.TotalFile += n
 	}
 	for _, n := range stats.Count[TypeError:] {
@@ -65,21 +110,7 @@ func (stats Statistics) Log(logger *slog.Logger) {
 func (stats Statistics) LogAll(logger *slog.Logger) {
 	stats.Log(logger)
 
-	type2name := [...]string{
-		TypeKnow:            "know",
-		TypeRedirect:        "redirect",
-		TypeFileRobots:      "fileRobots",
-		TypeFileHTML:        "fileHTML",
-		TypeFileRSS:         "fileRSS",
-		TypeFileSitemap:     "fileSitemap",
-		TypeFileFavicon:     "fileFavicon",
-		TypeErrorNetwork:    "errorNetwork",
-		TypeErrorParsing:    "errorParsing",
-		TypeErrorFilterURL:  "errorFilterURL",
-		TypeErrorFilterPage: "errorFilterPage",
-	}
-
-	for t, name := range type2name {
+	for t, name := range typeNames {
 		if name == "" {
 			continue
 		}
@@ -93,8 +124,14 @@ func (stats Statistics) LogAll(logger *slog.Logger) {
 			"type", name)
 	}
 
+	for status, count := range stats.HTTPStatus {
+		if count > 0 {
+			logger.Info("db.stats.status", "count", count, "status", status)
+		}
+	}
+
 	logger.Info("db.stats.size", "total", stats.TotalFileSize)
-	for t, name := range type2name[:TypeErrorNetwork] {
+	for t, name := range typeNames[:TypeAlias] {
 		if byte(t) < TypeFileRobots || name == "" {
 			continue
 		}
@@ -21,10 +21,13 @@ func TestGetStatistics(t *testing.T) {
 		keys.NewString("key5"): metavalue{Type: TypeFileSitemap, Length: 5},
 		keys.NewString("key6"): metavalue{Type: TypeFileFavicon, Length: 6},
 
+		keys.NewString("key12"): metavalue{Type: TypeAliasCanonical},
+
 		keys.NewString("key7"):  metavalue{Type: TypeErrorNetwork},
 		keys.NewString("key8"):  metavalue{Type: TypeErrorParsing},
 		keys.NewString("key9"):  metavalue{Type: TypeErrorFilterURL},
 		keys.NewString("key10"): metavalue{Type: TypeErrorFilterPage},
+		keys.NewString("key11"): metavalue{Type: TypeErrorNotFound, Status: 404},
 	}
 	assert.Equal(t, Statistics{
 		Count: [256]int{
@@ -37,14 +40,17 @@ func TestGetStatistics(t *testing.T) {
 			TypeFileSitemap: 1,
 			TypeFileFavicon: 1,
 
+			TypeAliasCanonical: 1,
+
 			TypeErrorNetwork:    1,
 			TypeErrorParsing:    1,
 			TypeErrorFilterURL:  1,
 			TypeErrorFilterPage: 1,
+			TypeErrorNotFound:   1,
 		},
-		Total:      11,
+		Total:      13,
 		TotalFile:  5,
-		TotalError: 4,
+		TotalError: 5,
 
 		FileSize: [TypeError]int64{
 			TypeFileRobots:  2,
@@ -55,6 +61,7 @@ func TestGetStatistics(t *testing.T) {
 		},
 
 		TotalFileSize: 20,
+		HTTPStatus:    [600]int{404: 1},
 	}, getStatistics(m))
 	assert.Equal(t, 1, (&Database[any]{mapMeta: m}).CountHTML())
 }
@@ -85,6 +92,7 @@ func TestStatisticsLog(t *testing.T) {
 			TypeFileFavicon: 6,
 		},
 		TotalFileSize: 20,
+		HTTPStatus:    [600]int{404: 2, 503: 1},
 	}
 
 	records, handler := sloghandlers.NewHandlerRecords(slog.DebugLevel)
@@ -104,15 +112,33 @@ func TestStatisticsLog(t *testing.T) {
 		"INFO [db.stats.count] count=+001 percent=+009 type=fileRSS",
 		"INFO [db.stats.count] count=+001 percent=+009 type=fileSitemap",
 		"INFO [db.stats.count] count=+001 percent=+009 type=fileFavicon",
+		"INFO [db.stats.count] count=+000 percent=+000 type=fileHost",
+		"INFO [db.stats.count] count=+000 percent=+000 type=aliasCanonical",
+		"INFO [db.stats.count] count=+000 percent=+000 type=aliasDuplicate",
 		"INFO [db.stats.count] count=+001 percent=+009 type=errorNetwork",
 		"INFO [db.stats.count] count=+001 percent=+009 type=errorParsing",
 		"INFO [db.stats.count] count=+001 percent=+009 type=errorFilterURL",
 		"INFO [db.stats.count] count=+001 percent=+009 type=errorFilterPage",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorRobot",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorNoIndex",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorDNS",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorTimeout",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorTLS",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorTooLarge",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorNoIndexHeader",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorNoIndexAgent",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorNotFound",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorGone",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorClient",
+		"INFO [db.stats.count] count=+000 percent=+000 type=errorServer",
+		"INFO [db.stats.status] count=+002 status=+404",
+		"INFO [db.stats.status] count=+001 status=+503",
 		"INFO [db.stats.size] total=+020",
 		"INFO [db.stats.size] size=+002 percent=+010 type=fileRobots",
 		"INFO [db.stats.size] size=+003 percent=+015 type=fileHTML",
 		"INFO [db.stats.size] size=+004 percent=+020 type=fileRSS",
 		"INFO [db.stats.size] size=+005 percent=+025 type=fileSitemap",
 		"INFO [db.stats.size] size=+006 percent=+030 type=fileFavicon",
+		"INFO [db.stats.size] size=+000 percent=+000 type=fileHost",
 	}, *records)
 }
@@ -4,9 +4,54 @@ import (
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"golang.org/x/exp/slog"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
+// The origin of a known URL, saved with the URL in the urls file.
+type Origin struct {
+	// The page where the URL was found, nil for a seed.
+	Source *url.URL
+	// The number of links from a seed.
+	Depth int
+	// The rule that create a speculative URL (like "parent-path"), empty
+	// if the URL is a real link.
+	Speculative string
+}
+
+// Encode the URL and its origin in one line (without \n), the fields are
+// separated by tabulation: URL, depth, speculative rule and source.
+// If origin is nil, only the URL is saved.
+func formatURLLine(u *url.URL, origin *Origin) string {
+	if origin == nil {
+		return u.String()
+	}
+	source := ""
+	if origin.Source != nil {
+		source = origin.Source.String()
+	}
+	return u.String() + "\t" + strconv.Itoa(origin.Depth) + "\t" + origin.Speculative + "\t" + source
+}
+
+// Parse a line of the urls file, return the URL as string and its origin.
+// The origin is nil for an old line without origin.
+func parseURLLine(line string) (string, *Origin) {
+	s, fields, ok := strings.Cut(line, "\t")
+	if !ok {
+		return line, nil
+	}
+
+	origin := &Origin{}
+	depth, fields, _ := strings.Cut(fields, "\t")
+	origin.Depth, _ = strconv.Atoi(depth)
+	origin.Speculative, fields, _ = strings.Cut(fields, "\t")
+	if fields != "" {
+		origin.Source, _ = url.Parse(fields)
+	}
+
+	return s, origin
+}
+
 // Load URLS from the data (url encoded as string sepatared by \n).
 // Use the logger as warn when url parsing error cooure.
 // Do not return URL with not accepted type in the mapMeta.
@@ -23,6 +68,7 @@ func loadURLs(logger *slog.Logger, data []byte, mapMeta map[keys.Key]metavalue,
 	urls := make([]*url.URL, 0, len(lines))
 
 	for line, s := range lines {
+		s, _ = parseURLLine(s)
 		if refusedTypes[mapMeta[keys.NewString(s)].Type] {
 			continue
 		}
@@ -6,7 +6,9 @@ import (
 	"github.com/HuguesGuilleus/isty-search/sloghandlers"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/exp/slog"
+	"net/url"
 	"testing"
+	"time"
 )
 
 func TestLoadURLs(t *testing.T) {
@@ -31,3 +33,60 @@ func TestLoadURLs(t *testing.T) {
 	), received)
 	assert.Nil(t, *records)
 }
+
+func TestURLLine(t *testing.T) {
+	u := common.ParseURL("https://www.example.org/")
+	origin := &Origin{
+		Source:      common.ParseURL("https://blog.example.org/page"),
+		Depth:       2,
+		Speculative: "www",
+	}
+
+	line := formatURLLine(u, origin)
+	assert.Equal(t, "https://www.example.org/\t2\twww\thttps://blog.example.org/page", line)
+	s, parsedOrigin := parseURLLine(line)
+	assert.Equal(t, "https://www.example.org/", s)
+	assert.Equal(t, origin, parsedOrigin)
+
+	line = formatURLLine(u, &Origin{})
+	assert.Equal(t, "https://www.example.org/\t0\t\t", line)
+	s, parsedOrigin = parseURLLine(line)
+	assert.Equal(t, "https://www.example.org/", s)
+	assert.Equal(t, &Origin{}, parsedOrigin)
+
+	s, parsedOrigin = parseURLLine("https://www.example.org/")
+	assert.Equal(t, "https://www.example.org/", s)
+	assert.Nil(t, parsedOrigin)
+}
+
+func TestOrigins(t *testing.T) {
+	_, db, _ := OpenMemory[struct{}](nil, "", false)
+	seed := common.ParseURL("https://example.org/")
+	link := common.ParseURL("https://example.org/dir/page")
+	parent := common.ParseURL("https://example.org/dir/")
+	old := common.ParseURL("https://example.org/old")
+
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{keys.NewURL(old): old}, nil))
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{keys.NewURL(seed): seed}, map[keys.Key]Origin{
+		keys.NewURL(seed): {},
+	}))
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{
+		keys.NewURL(link):   link,
+		keys.NewURL(parent): parent,
+	}, map[keys.Key]Origin{
+		keys.NewURL(link):   {Source: seed, Depth: 1},
+		keys.NewURL(parent): {Source: seed, Depth: 1, Speculative: "parent-path"},
+	}))
+
+	origins, err := db.Origins()
+	assert.NoError(t, err)
+	assert.Equal(t, map[keys.Key]Origin{
+		keys.NewURL(seed):   {},
+		keys.NewURL(link):   {Source: seed, Depth: 1},
+		keys.NewURL(parent): {Source: seed, Depth: 1, Speculative: "parent-path"},
+	}, origins)
+
+	urls, err := db.URLsBefore(TypeKnow, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, urls, 4)
+}
@@ -0,0 +1,173 @@
+// Parse RSS (1.0 and 2.0) and Atom feeds.
+//
+// Specifications:
+//   - https://www.rssboard.org/rss-specification
+//   - https://www.rfc-editor.org/rfc/rfc4287
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/charset"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var NotFeed = errors.New("The root element is not rss, RDF or feed")
+
+// A parsed RSS or Atom feed.
+type Feed struct {
+	Title string
+	Items []Item
+}
+
+// One item of the feed (an article).
+type Item struct {
+	Title string
+	// The link of the item, can be relative to the feed URL.
+	Link url.URL
+	// The publication or update date, zero if unknown.
+	Date time.Time
+}
+
+type xmlItem struct {
+	Title   string   `xml:"title"`
+	Links   []string `xml:"link"`
+	PubDate string   `xml:"pubDate"`
+	Date    string   `xml:"date"`
+}
+
+type xmlEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+type xmlFeed struct {
+	XMLName xml.Name
+	// RSS 2.0
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []xmlItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0, the items are outside the channel.
+	Items []xmlItem `xml:"item"`
+	// Atom
+	Title   string     `xml:"title"`
+	Entries []xmlEntry `xml:"entry"`
+}
+
+// Parse the feed. The items without valid link are ignored.
+func Parse(data []byte) (*Feed, error) {
+	decoded := xmlFeed{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReader
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	switch decoded.XMLName.Local {
+	case "rss":
+		return &Feed{
+			Title: strings.TrimSpace(decoded.Channel.Title),
+			Items: convertItems(decoded.Channel.Items),
+		}, nil
+	case "RDF":
+		return &Feed{
+			Title: strings.TrimSpace(decoded.Channel.Title),
+			Items: convertItems(decoded.Items),
+		}, nil
+	case "feed":
+		return &Feed{
+			Title: strings.TrimSpace(decoded.Title),
+			Items: convertEntries(decoded.Entries),
+		}, nil
+	default:
+		return nil, NotFeed
+	}
+}
+
+func convertItems(src []xmlItem) []Item {
+	items := make([]Item, 0, len(src))
+	for _, item := range src {
+		for _, link := range item.Links {
+			u, _ := url.Parse(strings.TrimSpace(link))
+			if u == nil || u.String() == "" {
+				continue
+			}
+			date := parseDate(item.PubDate)
+			if date.IsZero() {
+				date = parseDate(item.Date)
+			}
+			items = append(items, Item{
+				Title: strings.TrimSpace(item.Title),
+				Link:  *u,
+				Date:  date,
+			})
+			break
+		}
+	}
+	return items
+}
+
+func convertEntries(src []xmlEntry) []Item {
+	items := make([]Item, 0, len(src))
+	for _, entry := range src {
+		for _, link := range entry.Links {
+			if link.Rel != "" && link.Rel != "alternate" {
+				continue
+			}
+			u, _ := url.Parse(strings.TrimSpace(link.Href))
+			if u == nil || u.String() == "" {
+				continue
+			}
+			date := parseDate(entry.Updated)
+			if date.IsZero() {
+				date = parseDate(entry.Published)
+			}
+			items = append(items, Item{
+				Title: strings.TrimSpace(entry.Title),
+				Link:  *u,
+				Date:  date,
+			})
+			break
+		}
+	}
+	return items
+}
+
+// Parse the date with the RSS or the Atom format.
+// Return zero time on error.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range [...]string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2006-01-02",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Return true if the root element of data is a RSS or a Atom feed.
+func Is(data []byte) bool {
+	switch common.XMLRootName(data, charset.NewReader) {
+	case "rss", "RDF", "feed":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package feed
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRSS(t *testing.T) {
+	feed, err := Parse([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+	<title>News</title>
+	<atom:link href="https://example.org/rss.xml" rel="self" type="application/rss+xml" />
+	<item>
+		<title>First</title>
+		<link>https://example.org/news/first</link>
+		<pubDate>Tue, 10 Jan 2023 08:30:00 +0100</pubDate>
+	</item>
+	<item>
+		<title>No link</title>
+	</item>
+	<item>
+		<title>Second</title>
+		<link>/news/second</link>
+	</item>
+</channel>
+</rss>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Feed{
+		Title: "News",
+		Items: []Item{
+			{
+				Title: "First",
+				Link:  url.URL{Scheme: "https", Host: "example.org", Path: "/news/first"},
+				Date:  time.Date(2023, time.January, 10, 8, 30, 0, 0, time.FixedZone("", 3600)),
+			},
+			{
+				Title: "Second",
+				Link:  url.URL{Path: "/news/second"},
+			},
+		},
+	}, feed)
+}
+
+func TestParseRDF(t *testing.T) {
+	feed, err := Parse([]byte(`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+	<channel><title>RDF</title></channel>
+	<item><title>Item</title><link>https://example.org/item</link></item>
+</rdf:RDF>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Feed{
+		Title: "RDF",
+		Items: []Item{{
+			Title: "Item",
+			Link:  url.URL{Scheme: "https", Host: "example.org", Path: "/item"},
+		}},
+	}, feed)
+}
+
+func TestParseAtom(t *testing.T) {
+	feed, err := Parse([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Atom</title>
+	<link href="https://example.org/atom.xml" rel="self" />
+	<entry>
+		<title>Entry</title>
+		<link rel="edit" href="https://example.org/edit/1"/>
+		<link href="https://example.org/entry/1"/>
+		<updated>2003-12-13T18:30:02Z</updated>
+	</entry>
+</feed>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &Feed{
+		Title: "Atom",
+		Items: []Item{{
+			Title: "Entry",
+			Link:  url.URL{Scheme: "https", Host: "example.org", Path: "/entry/1"},
+			Date:  time.Date(2003, time.December, 13, 18, 30, 2, 0, time.UTC),
+		}},
+	}, feed)
+
+	feed, err = Parse([]byte(`<urlset></urlset>`))
+	assert.Nil(t, feed)
+	assert.Equal(t, NotFeed, err)
+}
+
+func TestIs(t *testing.T) {
+	assert.True(t, Is([]byte(`<?xml version="1.0"?><rss version="2.0">`)))
+	assert.True(t, Is([]byte(`<feed xmlns="http://www.w3.org/2005/Atom">`)))
+	assert.False(t, Is([]byte(`<!DOCTYPE html><html></html>`)))
+}
+
+func TestParseLatin1(t *testing.T) {
+	data := []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>\xC9t\xE9</title></channel></rss>")
+	assert.True(t, Is(data))
+	feed, err := Parse(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Été", feed.Title)
+}
@@ -3,12 +3,21 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/charset"
 	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/feed"
 	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt"
+	"github.com/HuguesGuilleus/isty-search/crawler/sitemap"
 	"github.com/HuguesGuilleus/isty-search/keys"
-	"io"
+	"golang.org/x/exp/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"runtime/debug"
@@ -24,6 +33,28 @@ type fetchContext struct {
 	// The key is generate by createKey().
 	hosts      map[string]*host
 	hostsMutex sync.Mutex
+	// The number of URLs given to workers by host (key from createKey()).
+	hostsPlanned map[string]int
+	// The weight of each host, see Config.HostWeight.
+	hostWeight map[string]float64
+	// The limits of each host, see Config.HostLimits.
+	hostLimits map[string]HostLimits
+	// See Config.MaxDepth and Config.MaxPagesPerHost.
+	maxDepth, maxPagesPerHost int
+	// Parked host (see HostState) for this crawl, key from createKey().
+	parked map[string]bool
+	// See Config.MaxHostErrors and Config.HostParkDuration.
+	maxHostErrors    int
+	hostParkDuration time.Duration
+	// Paused by the admin API: the whole crawl or hosts (key from createKey()).
+	paused      bool
+	pausedHosts map[string]bool
+	// The URL fetched by each host, key from createKey().
+	inFlight map[string]*url.URL
+	// The hosts whose sitemaps are added, key from createKey().
+	sitemapsAdded map[string]bool
+
+	logger *slog.Logger
 
 	// A parent context
 	context context.Context
@@ -33,11 +64,16 @@ type fetchContext struct {
 	maxGo int
 	// Done when all crawl goroutine return.
 	wg sync.WaitGroup
+	// Broadcasted when a crawl goroutine returns, see closeWorkers().
+	workerEnd sync.Cond
+	// The crawl is over, no crawl goroutine is launched.
+	closed bool
 
 	filterURL  []func(*url.URL) bool
 	filterPage []func(*htmlnode.Root) bool
 
-	roundTripper http.RoundTripper
+	// The HTTP client, its User-Agent select the robots.txt group.
+	fetcher *Fetcher
 
 	// The max size of the html page.
 	maxLength int64
@@ -46,24 +82,39 @@ type fetchContext struct {
 	// The used value if determined by the robots.txt.
 	// Must: minCrawlDelay < maxCrawlDelay
 	minCrawlDelay, maxCrawlDelay time.Duration
-}
 
-type host struct {
-	scheme   string
-	host     string
-	urls     []*url.URL
-	fetching bool
+	// The min and max interval between two fetch of a HTML page.
+	revisitAge, revisitMaxAge time.Duration
+
+	// The SimHash of the HTML pages, to find near-duplicate.
+	fingerprints *fingerprintIndex
+
+	// The rules to add speculative URLs from links.
+	speculative Speculative
+
+	// Forward the URLs to other shards, nil if the crawl is not sharded.
+	shards *shardForwarder
+
+	// The number of successive redirections to reach the planned URLs,
+	// limited by Fetcher.MaxRedirect.
+	redirectsMutex sync.Mutex
+	redirects      map[keys.Key]int
 }
 
 func (ctx *fetchContext) Work() {
 	defer ctx.wg.Done()
-	for h := ctx.tryChooseWork(nil); h != nil && ctx.context.Err() == nil; h = ctx.tryChooseWork(h) {
-		ctx.crawlHost(h)
+	for b := ctx.tryChooseWork(nil); b != nil && ctx.context.Err() == nil; b = ctx.tryChooseWork(b) {
+		if !b.wait.IsZero() {
+			ctx.sleepUntil(b.wait)
+			continue
+		}
+		ctx.crawlHost(b)
 	}
 }
 
-// Crawl (strike and )
-func (ctx *fetchContext) crawlHost(h *host) {
+// Crawl (strike and ) the URLs of the batch. If the host fail, stop and
+// keep the not fetched URLs in b.items.
+func (ctx *fetchContext) crawlHost(b *batch) {
 	defer func() {
 		if err := recover(); err != nil {
 			fmt.Println("[defered error]", err)
@@ -71,66 +122,218 @@ func (ctx *fetchContext) crawlHost(h *host) {
 		}
 	}()
 
-	urls, crawDelay := ctx.strikeURLs(h)
-	for _, u := range urls {
-		ctx.sleep(crawDelay)
-		ctx.fetchOne(u)
+	items, crawDelay, robotsUnreachable := ctx.strikeURLs(b)
+	b.items = nil
+	b.delay = ctx.delay(b.host, crawDelay)
+	if robotsUnreachable != nil {
+		// The robots.txt disallows all, the URLs are crawled later.
+		b.items = items
+		if ctx.updateHostState(b.scheme, b.host, &b.state, robotsUnreachable, crawDelay) {
+			ctx.saveHostState(b.scheme, b.host, b.state)
+		}
+		return
+	}
+	hostKey := createKey(b.scheme, b.host)
+	defer ctx.setInFlight(hostKey, nil)
+	for i, item := range items {
+		ctx.sleep(b.host, crawDelay)
+		if !ctx.setInFlight(hostKey, item.url) {
+			b.items = items[i:]
+			return
+		}
+		result := ctx.fetchOne(item.url, item.depth)
+		if ctx.updateHostState(b.scheme, b.host, &b.state, &result, crawDelay) {
+			ctx.saveHostState(b.scheme, b.host, b.state)
+		}
 		if ctx.context.Err() != nil {
 			return
+		} else if now := time.Now(); b.state.NotBefore.After(now) || b.state.ParkedUntil.After(now) {
+			b.items = items[i+1:]
+			return
+		}
+	}
+
+	if len(items) > 0 {
+		result := ctx.fetchFavicon(b.scheme, b.host, crawDelay)
+		if ctx.updateHostState(b.scheme, b.host, &b.state, &result, crawDelay) {
+			ctx.saveHostState(b.scheme, b.host, b.state)
 		}
 	}
 }
 
-// Get a host that will be crawled, and free last host if not nil.
-func (ctx *fetchContext) tryChooseWork(lastHost *host) *host {
+// Set the URL fetched by the host, nil at the end of the batch. Return false
+// if the host or the crawl is paused, so the URL must not be fetched.
+func (ctx *fetchContext) setInFlight(hostKey string, u *url.URL) bool {
 	ctx.hostsMutex.Lock()
 	defer ctx.hostsMutex.Unlock()
 
-	if lastHost != nil {
-		key := createKey(lastHost.scheme, lastHost.host)
-		ctx.hosts[key].fetching = false
-		if len(ctx.hosts[key].urls) == 0 {
+	if u == nil {
+		delete(ctx.inFlight, hostKey)
+		return true
+	} else if ctx.paused || ctx.pausedHosts[hostKey] {
+		delete(ctx.inFlight, hostKey)
+		return false
+	}
+	ctx.inFlight[hostKey] = u
+	return true
+}
+
+// Get the best URLs of the host with the best priority, and free last host
+// if not nil. The number of URLs is limited by frontierBatch and the
+// budget of the host.
+//
+// If all hosts wait (see HostState.NotBefore) or are paused, and it's the
+// last worker, return a batch to wait.
+func (ctx *fetchContext) tryChooseWork(last *batch) *batch {
+	ctx.hostsMutex.Lock()
+	defer ctx.hostsMutex.Unlock()
+
+	now := time.Now()
+	if last != nil && last.wait.IsZero() {
+		key := createKey(last.scheme, last.host)
+		h := ctx.hosts[key]
+		h.fetching = false
+		h.delay = last.delay
+		h.state = last.state
+		// The not fetched URLs are given back to the budget of the host.
+		ctx.hostsPlanned[key] -= len(last.items)
+		if h.state.ParkedUntil.After(now) {
+			h.clear()
+			ctx.parked[key] = true
+		} else {
+			for _, item := range last.items {
+				h.pushItem(item)
+			}
+		}
+		if len(h.queue) == 0 {
 			delete(ctx.hosts, key)
 		}
 	}
 
-	for _, h := range ctx.hosts {
-		if !h.fetching {
-			h.fetching = true
-			returnedHost := *h
-			h.urls = nil
-			return &returnedHost
+	// MaxGo is decreased by the admin API.
+	if ctx.lenGo > ctx.maxGo {
+		ctx.lenGo--
+		ctx.workerEnd.Broadcast()
+		return nil
+	}
+
+	best := (*host)(nil)
+	bestKey := ""
+	wait := time.Time{}
+	for key, h := range ctx.hosts {
+		if h.fetching {
+			continue
+		} else if ctx.paused || ctx.pausedHosts[key] {
+			if next := now.Add(pausePoll); wait.IsZero() || next.Before(wait) {
+				wait = next
+			}
+		} else if h.state.NotBefore.After(now) {
+			if wait.IsZero() || h.state.NotBefore.Before(wait) {
+				wait = h.state.NotBefore
+			}
+		} else if best == nil || h.priority() > best.priority() {
+			best = h
+			bestKey = key
+		}
+	}
+	if best == nil {
+		if !wait.IsZero() && ctx.lenGo == 1 {
+			return &batch{wait: wait}
 		}
+		ctx.lenGo--
+		ctx.workerEnd.Broadcast()
+		return nil
+	}
+
+	n := frontierBatch
+	if ctx.maxPagesPerHost > 0 && ctx.maxPagesPerHost-ctx.hostsPlanned[bestKey] < n {
+		n = ctx.maxPagesPerHost - ctx.hostsPlanned[bestKey]
+	}
+	best.fetching = true
+	items := best.pop(n)
+	ctx.hostsPlanned[bestKey] += len(items)
+	if ctx.maxPagesPerHost > 0 && ctx.hostsPlanned[bestKey] >= ctx.maxPagesPerHost {
+		best.clear()
 	}
 
-	ctx.lenGo--
-	return nil
+	return &batch{
+		scheme: best.scheme,
+		host:   best.host,
+		items:  items,
+		state:  best.state,
+	}
 }
 
 // Add urls in the URLsDB and in the ctx.host, then lauch if it's possible new crawl goroutine.
-func (ctx *fetchContext) addURLs(urls map[keys.Key]*url.URL) {
-	ctx.db.AddURL(urls)
-	ctx.planURLs(urls)
+// The source is the page where the URLs are found, and rules the rule of
+// the speculative URLs (can be nil), they are saved as origin of new URLs.
+// The URLs deeper than maxDepth are ignored. Known URLs that are not yet
+// crawled get one more inbound link.
+func (ctx *fetchContext) addURLs(source *url.URL, urls map[keys.Key]*url.URL, rules map[keys.Key]string, depth int) {
+	if ctx.maxDepth > 0 && depth > ctx.maxDepth {
+		return
+	}
+
+	origins := make(map[keys.Key]crawldatabase.Origin, len(urls))
+	for key := range urls {
+		origins[key] = crawldatabase.Origin{
+			Source:      source,
+			Depth:       depth,
+			Speculative: rules[key],
+		}
+	}
+
+	ctx.hostsMutex.Lock()
+	for key, u := range urls {
+		if h := ctx.hosts[createKey(u.Scheme, u.Host)]; h != nil {
+			h.incInbound(key)
+		}
+	}
+	ctx.hostsMutex.Unlock()
+
+	ctx.db.AddURL(urls, origins)
+	ctx.shards.forward(urls, origins)
+	ctx.planURLs(urls, depth)
 }
 
-func (ctx *fetchContext) planURLs(urls map[keys.Key]*url.URL) {
+func (ctx *fetchContext) planURLs(urls map[keys.Key]*url.URL, depth int) {
 	ctx.hostsMutex.Lock()
 	defer ctx.hostsMutex.Unlock()
 
-	for _, u := range urls {
-		key := createKey(u.Scheme, u.Host)
-		h := ctx.hosts[key]
+	for key, u := range urls {
+		hostKey := createKey(u.Scheme, u.Host)
+		if ctx.parked[hostKey] || ctx.maxPagesPerHost > 0 && ctx.hostsPlanned[hostKey] >= ctx.maxPagesPerHost {
+			continue
+		}
+		h := ctx.hosts[hostKey]
 		if h == nil {
-			h = &host{
-				scheme: u.Scheme,
-				host:   u.Host,
-				urls:   make([]*url.URL, 0),
+			state := ctx.loadHostState(u.Scheme, u.Host)
+			if state.ParkedUntil.After(time.Now()) {
+				ctx.parked[hostKey] = true
+				continue
+			}
+			weight, ok := ctx.hostWeight[u.Host]
+			if !ok {
+				weight = 1
 			}
-			ctx.hosts[key] = h
+			h = newHost(u.Scheme, u.Host, weight)
+			h.state = state
+			ctx.hosts[hostKey] = h
+		}
+		if h.queued[key] == nil {
+			h.push(key, u, depth)
 		}
-		h.urls = append(h.urls, u)
 	}
 
+	ctx.startWorkers()
+}
+
+// Launch new crawl goroutine, at most one by host and maxGo, except if
+// the crawl is closed. ctx.hostsMutex must be locked.
+func (ctx *fetchContext) startWorkers() {
+	if ctx.closed {
+		return
+	}
 	max := len(ctx.hosts)
 	if max > ctx.maxGo {
 		max = ctx.maxGo
@@ -142,38 +345,151 @@ func (ctx *fetchContext) planURLs(urls map[keys.Key]*url.URL) {
 	}
 }
 
+// Wait until all crawl goroutines return, then close the crawl so new URLs
+// (from the admin API) can not launch crawl goroutine during ctx.wg.Wait().
+func (ctx *fetchContext) closeWorkers() {
+	ctx.hostsMutex.Lock()
+	for ctx.lenGo > 0 {
+		ctx.workerEnd.Wait()
+	}
+	ctx.closed = true
+	ctx.hostsMutex.Unlock()
+
+	ctx.wg.Wait()
+}
+
+// Get and forget the number of successive redirections to reach key.
+func (ctx *fetchContext) takeRedirects(key keys.Key) int {
+	ctx.redirectsMutex.Lock()
+	defer ctx.redirectsMutex.Unlock()
+
+	n := ctx.redirects[key]
+	delete(ctx.redirects, key)
+	return n
+}
+
+// Set the number of successive redirections to reach key.
+func (ctx *fetchContext) setRedirects(key keys.Key, n int) {
+	ctx.redirectsMutex.Lock()
+	defer ctx.redirectsMutex.Unlock()
+
+	if ctx.redirects == nil {
+		ctx.redirects = make(map[keys.Key]int)
+	}
+	ctx.redirects[key] = n
+}
+
 // Join the scheme and the host with two point.
 func createKey(scheme, host string) string { return scheme + ":" + host }
 
 /* FETCHING ONE */
 
-func (ctx *fetchContext) fetchOne(u *url.URL) {
+// Fetch the URL, the depth is used for the found URLs.
+// Return the fetch result, without the body.
+func (ctx *fetchContext) fetchOne(u *url.URL, depth int) (result fetchResult) {
 	key := keys.NewURL(u)
 
+	// The previous version of the page
+	previous := ctx.getPrevious(key)
+
 	// Get the body
-	body, redirect, errString := fetchBytes(ctx.context, ctx.roundTripper, ctx.maxLength, u)
-	if errString {
-		ctx.db.SetSimple(key, crawldatabase.TypeErrorNetwork)
+	result = ctx.fetcher.fetch(ctx.context, ctx.hostMaxLength(u.Host), u, previous.conditionalHeader())
+	if result.err && ctx.context.Err() != nil {
+		// The crawl is canceled, the URL will be fetched later.
+		return
+	}
+	ctx.saveResult(key, u, depth, previous, result)
+	result.body = nil
+
+	return
+}
+
+// Save into the database the result of the fetch of u: parse the body
+// and add the found URLs (at depth+1). The previous version of the page
+// can be nil. The body of result is recycled.
+func (ctx *fetchContext) saveResult(key keys.Key, u *url.URL, depth int, previous *Page, result fetchResult) {
+	redirects := ctx.takeRedirects(key)
+	if result.notModified && previous != nil {
+		ctx.refresh(key, previous, &result)
 		return
-	} else if redirect != nil {
-		ctx.addURLs(map[keys.Key]*url.URL{
+	} else if result.notModified {
+		// Unexpected response, it's not a conditional request.
+		ctx.db.SetError(key, crawldatabase.HTTPErrorType(result.status), result.status)
+		return
+	} else if result.err {
+		ctx.db.SetError(key, result.errType, result.status)
+		return
+	} else if redirect := result.redirect; redirect != nil {
+		if redirects >= ctx.fetcher.MaxRedirect {
+			ctx.db.SetError(key, crawldatabase.HTTPErrorType(result.status), result.status)
+			return
+		}
+		ctx.setRedirects(keys.NewURL(redirect), redirects+1)
+		ctx.addURLs(u, map[keys.Key]*url.URL{
 			keys.NewURL(redirect): redirect,
-		})
+		}, nil, depth)
 		ctx.db.SetRedirect(key, keys.NewURL(redirect))
 		return
 	}
+	response := result.pageResponse()
+	body := result.body
 	defer common.RecycleBuffer(body)
 
-	// Parse the body
-	htmlRoot, err := htmlnode.Parse(body.Bytes())
+	// Decompress the body
+	data := body.Bytes()
+	if unzipped, err := gunzip(data, ctx.hostMaxLength(u.Host)); err != nil {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	} else if unzipped != nil {
+		defer common.RecycleBuffer(unzipped)
+		data = unzipped.Bytes()
+	}
+
+	// Same content
+	hash := keys.Key(sha256.Sum256(data))
+	if previous != nil && previous.Revisit.Hash == hash {
+		ctx.refresh(key, previous, &result)
+		return
+	}
+
+	// Sitemap and feed
+	if sitemap.Is(data) {
+		ctx.saveSitemap(key, u, data, response)
+		return
+	} else if feed.Is(data) {
+		ctx.saveFeed(key, u, data, depth, response)
+		return
+	}
+
+	// Transcode and parse the body
+	charsetName := charset.Detect(result.contentType, data)
+	htmlRoot, err := htmlnode.Parse(charset.ToUTF8(charsetName, data))
 	if err != nil {
 		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
 		return
 	}
 
+	// Meta refresh redirection, after too many redirections it's a page.
+	if redirect := getRefresh(u, &htmlRoot.Meta); redirect != nil && redirects < ctx.fetcher.MaxRedirect {
+		ctx.setRedirects(keys.NewURL(redirect), redirects+1)
+		ctx.addURLs(u, map[keys.Key]*url.URL{
+			keys.NewURL(redirect): redirect,
+		}, nil, depth)
+		ctx.db.SetRedirect(key, keys.NewURL(redirect))
+		return
+	}
+
+	// Not canonical page
+	if canonical := getCanonical(u, result.canonical, &htmlRoot.Meta); canonical != nil {
+		if ctx.saveCanonical(u, canonical, depth) {
+			return
+		}
+	}
+
 	// Post filter
-	if htmlRoot.Meta.NoIndex {
-		ctx.db.SetSimple(key, crawldatabase.TypeErrorNoIndex)
+	noIndexType, noFollow := robotsDirectives(htmlRoot, result.robotsTag, robotstxt.ProductToken(ctx.fetcher.UserAgent))
+	if noIndexType != 0 {
+		ctx.db.SetSimple(key, noIndexType)
 		return
 	}
 	for _, filter := range ctx.filterPage {
@@ -183,143 +499,227 @@ func (ctx *fetchContext) fetchOne(u *url.URL) {
 		}
 	}
 
+	// Near-duplicate
+	simHash := pageSimHash(htmlRoot)
+	if ctx.saveDuplicate(key, simHash) {
+		return
+	}
+
 	page := &Page{
-		URL:  *u,
-		Html: htmlRoot,
+		URL:      *u,
+		Html:     htmlRoot,
+		Charset:  charsetName,
+		Revisit:  ctx.nextRevisit(previous, &result, hash),
+		SimHash:  simHash,
+		NoFollow: noFollow,
+		Response: response,
 	}
 
 	// Get URL
-	if !htmlRoot.Meta.NoFollow {
-		ctx.addURLs(page.GetURLs())
+	if noFollow == "" {
+		urls, rules := page.getURLs(ctx.speculative)
+		ctx.addURLs(u, urls, rules, depth+1)
+		ctx.addURLs(u, page.GetFeedURLs(), nil, depth+1)
 	}
 
 	// Save it
 	ctx.db.SetValue(key, page, crawldatabase.TypeFileHTML)
-
-	return
 }
 
 // Strike all url (from same host), and return it with crawDelay.
 // - The path is "/robots.txt" or "/favicon.ico"
 // - Filtered
 // - Blocked by robots.
-func (ctx *fetchContext) strikeURLs(h *host) ([]*url.URL, int) {
-	robotsGetter := robotGetter(ctx.context, ctx.db, h.scheme, h.host, ctx.roundTripper)
-	validURLs := make([]*url.URL, 0, len(h.urls))
-
-urlFor:
-	for _, u := range h.urls {
+//
+// If some URL are valid, add once the sitemaps of the host (at sitemapDepth).
+// If the robots.txt is unreachable, return the not striked URLs with the
+// robots.txt fetch result, they must not be fetched now.
+func (ctx *fetchContext) strikeURLs(b *batch) ([]*frontierItem, int, *fetchResult) {
+	robotsGetter := robotGetter(ctx.context, ctx.db, b.scheme, b.host, ctx.fetcher)
+	validItems := make([]*frontierItem, 0, len(b.items))
+
+itemFor:
+	for i, item := range b.items {
+		u := item.url
 		switch u.Path {
 		case robotsPath, faviconPath:
-			continue urlFor
+			continue itemFor
 		}
 
 		// Context filters
 		for _, filter := range ctx.filterURL {
 			if filter(u) {
-				ctx.db.SetSimple(keys.NewURL(u), crawldatabase.TypeErrorFilterURL)
-				continue urlFor
+				ctx.db.SetSimple(item.key, crawldatabase.TypeErrorFilterURL)
+				continue itemFor
 			}
 		}
 
 		// Robots.txt
-		if !robotsGetter().Allow(u) {
-			ctx.db.SetSimple(keys.NewURL(u), crawldatabase.TypeErrorRobot)
-			continue urlFor
+		robots, unreachable := robotsGetter()
+		if unreachable != nil {
+			return b.items[i:], 0, unreachable
+		} else if !robots.Allow(u) {
+			ctx.db.SetSimple(item.key, crawldatabase.TypeErrorRobot)
+			continue itemFor
 		}
 
-		validURLs = append(validURLs, u)
+		validItems = append(validItems, item)
 	}
 
-	if len(validURLs) == 0 {
-		return nil, 0
+	if len(validItems) == 0 {
+		return nil, 0, nil
 	}
 
-	return validURLs, robotsGetter().CrawlDelay
-}
-
-func (ctx *fetchContext) sleep(crawDelay int) {
-	delay := time.Duration(crawDelay) * time.Second
-	if delay < ctx.minCrawlDelay {
-		delay = ctx.minCrawlDelay
-	}
-	if delay > ctx.maxCrawlDelay {
-		delay = ctx.maxCrawlDelay
+	robots, _ := robotsGetter()
+	if ctx.firstSitemaps(b.scheme, b.host) {
+		robotsURL := &url.URL{Scheme: b.scheme, Host: b.host, Path: "/robots.txt"}
+		ctx.addURLs(robotsURL, sitemapURLs(b.scheme, b.host, robots), nil, sitemapDepth)
 	}
 
-	timeoutContext, cancel := context.WithTimeout(ctx.context, delay)
+	return validItems, robots.CrawlDelay, nil
+}
+
+// Sleep the delay of the host, see delay().
+func (ctx *fetchContext) sleep(host string, crawDelay int) {
+	timeoutContext, cancel := context.WithTimeout(ctx.context, ctx.delay(host, crawDelay))
 	defer cancel()
 	<-timeoutContext.Done()
 }
 
-// Fetch until the redirection is over maxRedirect.
-// Used my robotGet()
-func fetchMultiple(ctx context.Context, roundTripper http.RoundTripper, maxLength int64, u *url.URL, maxRedirect int) (buff *bytes.Buffer) {
-	for i := 0; i < maxRedirect && u != nil; i++ {
-		buff, u, _ = fetchBytes(ctx, roundTripper, maxLength, u)
-	}
-	return
+// Sleep until the instant t, or the context is done.
+func (ctx *fetchContext) sleepUntil(t time.Time) {
+	timeoutContext, cancel := context.WithDeadline(ctx.context, t)
+	defer cancel()
+	<-timeoutContext.Done()
 }
 
-// Fetch the url, and return: the body, the redirect URL or the error.
-func fetchBytes(ctx context.Context, roundTripper http.RoundTripper, maxLength int64, u *url.URL) (*bytes.Buffer, *url.URL, bool) {
-	if h := u.Host; strings.LastIndex(h, ":") > strings.LastIndex(h, "]") {
-		u.Host = strings.TrimSuffix(h, ":")
+// Get the delay from the crawDelay (in second), bounded by minCrawlDelay
+// and maxCrawlDelay, or by the limits of the host.
+func (ctx *fetchContext) delay(host string, crawDelay int) time.Duration {
+	minCrawlDelay, maxCrawlDelay := ctx.minCrawlDelay, ctx.maxCrawlDelay
+	if limits, ok := ctx.hostLimits[host]; ok {
+		if limits.MinCrawlDelay > 0 {
+			minCrawlDelay = limits.MinCrawlDelay
+		}
+		if limits.MaxCrawlDelay > 0 {
+			maxCrawlDelay = limits.MaxCrawlDelay
+		}
 	}
 
-	request := http.Request{
-		Method:     http.MethodGet,
-		URL:        u,
-		Proto:      "HTTP/1.1",
-		ProtoMajor: 1,
-		ProtoMinor: 1,
-		Header:     make(http.Header),
-		Host:       u.Host,
+	delay := time.Duration(crawDelay) * time.Second
+	if delay < minCrawlDelay {
+		delay = minCrawlDelay
 	}
-	requestContext, cancel := context.WithTimeout(ctx, time.Millisecond*100)
-	defer cancel()
-	request.WithContext(requestContext)
-	response, err := roundTripper.RoundTrip(&request)
-
-	if err != nil {
-		return nil, nil, true
+	if delay > maxCrawlDelay {
+		delay = maxCrawlDelay
 	}
-	defer response.Body.Close()
+	return delay
+}
 
-	if code := response.StatusCode / 100; code == 3 {
-		return getLocation(u, response)
-	} else if code != 2 {
-		return nil, nil, true
+// Get the max length of the pages of the host.
+func (ctx *fetchContext) hostMaxLength(host string) int64 {
+	if limits := ctx.hostLimits[host]; limits.MaxLength > 0 {
+		return limits.MaxLength
 	}
+	return ctx.maxLength
+}
 
-	buff := common.GetBuffer()
-	if l := response.ContentLength; l > 0 && l < maxLength {
-		buff.Grow(int(l))
-	} else {
-		buff.Grow(int(maxLength))
+// The result of Fetcher.fetch(), one of the body, the redirection, not
+// modified or the error.
+type fetchResult struct {
+	body        *bytes.Buffer
+	redirect    *url.URL
+	notModified bool
+	err         bool
+	// The error type for the database, if err is true.
+	errType byte
+
+	// The fetched URL, and the status code, zero if network error.
+	url    *url.URL
+	status int
+	// The Retry-After header, for 429 and 503 response.
+	retryAfter time.Duration
+
+	// Validators of the response, for the next conditional request.
+	etag, lastModified string
+	// The Content-Type header.
+	contentType string
+	// The canonical URL from the Link header, can be nil.
+	canonical *url.URL
+	// The X-Robots-Tag header values.
+	robotsTag []string
+	// The Content-Language and the Cache-Control headers.
+	contentLanguage string
+	cacheControl    string
+
+	// The duration of the fetch, and true if the body was bigger than
+	// the max length.
+	duration  time.Duration
+	truncated bool
+}
+
+// Create the Response of the page from the result.
+func (result *fetchResult) pageResponse() Response {
+	response := Response{
+		Status:          result.status,
+		ContentType:     result.contentType,
+		LastModified:    result.lastModified,
+		ETag:            result.etag,
+		ContentLanguage: result.contentLanguage,
+		Duration:        result.duration,
+		Truncated:       result.truncated,
 	}
-	if _, err := buff.ReadFrom(io.LimitReader(response.Body, maxLength)); err != nil {
-		common.RecycleBuffer(buff)
-		return nil, nil, true
+	if result.url != nil {
+		response.URL = *result.url
 	}
+	if result.body != nil {
+		response.BodySize = result.body.Len()
+	}
+	return response
+}
+
+// Get the database error type of the error from the round tripper or from
+// the body reading.
+func networkErrorType(err error) byte {
+	dnsError := (*net.DNSError)(nil)
+	netError := net.Error(nil)
+	switch {
+	case errors.As(err, &dnsError):
+		return crawldatabase.TypeErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+		return crawldatabase.TypeErrorTimeout
+	case isTLSError(err):
+		return crawldatabase.TypeErrorTLS
+	}
+	return crawldatabase.TypeErrorNetwork
+}
 
-	return buff, nil, false
+// The error come from the TLS handshake or the certificate verification.
+func isTLSError(err error) bool {
+	recordError := tls.RecordHeaderError{}
+	unknownAuthorityError := x509.UnknownAuthorityError{}
+	hostnameError := x509.HostnameError{}
+	invalidError := x509.CertificateInvalidError{}
+	return errors.As(err, &recordError) ||
+		errors.As(err, &unknownAuthorityError) ||
+		errors.As(err, &hostnameError) ||
+		errors.As(err, &invalidError) ||
+		strings.Contains(err.Error(), "tls: ")
 }
 
-// Get the location from response headers.
-// The bytes buffer is allways nil.
-func getLocation(u *url.URL, response *http.Response) (*bytes.Buffer, *url.URL, bool) {
+// Get the location from response headers, or nil if invalid.
+func getLocation(u *url.URL, response *http.Response) *url.URL {
 	redirectString := response.Header.Get("Location")
 	if redirectString == "" {
-		return nil, nil, true
+		return nil
 	}
 	redirect, err := u.Parse(redirectString)
 	if err != nil {
-		return nil, nil, true
+		return nil
 	}
 	cleanURL(redirect)
 	if redirect.String() == u.String() {
-		return nil, nil, true
+		return nil
 	}
-	return nil, redirect, false
+	return redirect
 }
@@ -0,0 +1,24 @@
+package crawler
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestNetworkErrorType(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.org/", Err: err}
+	}
+
+	assert.Equal(t, crawldatabase.TypeErrorDNS, networkErrorType(wrap(&net.DNSError{Err: "no such host", Name: "example.org"})))
+	assert.Equal(t, crawldatabase.TypeErrorTimeout, networkErrorType(wrap(context.DeadlineExceeded)))
+	assert.Equal(t, crawldatabase.TypeErrorTLS, networkErrorType(wrap(x509.UnknownAuthorityError{})))
+	assert.Equal(t, crawldatabase.TypeErrorTLS, networkErrorType(wrap(errors.New("remote error: tls: handshake failure"))))
+	assert.Equal(t, crawldatabase.TypeErrorNetwork, networkErrorType(wrap(errors.New("connection refused"))))
+}
@@ -0,0 +1,231 @@
+package crawler
+
+import (
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"golang.org/x/exp/slog"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The Accept header sent on each request.
+const fetcherAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// The HTTP client of the crawler.
+type Fetcher struct {
+	// Use to fetch all HTTP ressource. If nil, use a http.Transport that
+	// use ConnectTimeout.
+	RoundTripper http.RoundTripper
+	// The User-Agent header sent on each request. The product token (the
+	// first word, see robotstxt.ProductToken) select the robots.txt group.
+	// If empty, use DefaultUserAgent.
+	UserAgent string
+
+	// Timeout to open the connection (with the TLS handshake), to receive
+	// the response headers and to read the body.
+	// Default: 10s, 15s and 30s. ConnectTimeout is not used with a custom
+	// RoundTripper.
+	ConnectTimeout, HeaderTimeout, BodyTimeout time.Duration
+
+	// The maximum number of redirections followed for robots.txt and
+	// favicon, and the maximum of successive redirections of the pages
+	// (saved into the database) in one crawl. Default: 5.
+	MaxRedirect int
+}
+
+// Return a copy of the fetcher with the default values. All requests are
+// logged.
+func (f Fetcher) withDefault(logger *slog.Logger) *Fetcher {
+	if f.UserAgent == "" {
+		f.UserAgent = DefaultUserAgent
+	}
+	if f.ConnectTimeout <= 0 {
+		f.ConnectTimeout = time.Second * 10
+	}
+	if f.HeaderTimeout <= 0 {
+		f.HeaderTimeout = time.Second * 15
+	}
+	if f.BodyTimeout <= 0 {
+		f.BodyTimeout = time.Second * 30
+	}
+	if f.MaxRedirect <= 0 {
+		f.MaxRedirect = 5
+	}
+
+	if f.RoundTripper == nil {
+		dialer := &net.Dialer{
+			Timeout:   f.ConnectTimeout,
+			KeepAlive: time.Second * 30,
+		}
+		f.RoundTripper = &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       time.Second * 90,
+			TLSHandshakeTimeout:   f.ConnectTimeout,
+			ExpectContinueTimeout: time.Second,
+		}
+	}
+	f.RoundTripper = newlogRoundTripper(f.RoundTripper, logger)
+
+	return &f
+}
+
+// Fetch until the redirection is over MaxRedirect, and return the last
+// result, the body is nil on error. A body bigger than maxLength is always
+// truncated, even if the Content-Length is bigger. The header (can be nil)
+// is sent with each request. Used my robotGet()
+func (f *Fetcher) fetchMultiple(ctx context.Context, maxLength int64, u *url.URL, header http.Header) (result fetchResult) {
+	for i := 0; i < f.MaxRedirect && u != nil; i++ {
+		result = f.fetchMeasured(ctx, maxLength, true, u, header)
+		u = result.redirect
+	}
+	return
+}
+
+// Fetch the url with the header (can be nil), and return the result.
+// The request is canceled with the context or after the timeouts.
+// The result is measured in the metrics.
+func (f *Fetcher) fetch(ctx context.Context, maxLength int64, u *url.URL, header http.Header) fetchResult {
+	return f.fetchMeasured(ctx, maxLength, false, u, header)
+}
+
+func (f *Fetcher) fetchMeasured(ctx context.Context, maxLength int64, truncate bool, u *url.URL, header http.Header) fetchResult {
+	begin := time.Now()
+	result := f.fetchNoMetrics(ctx, maxLength, truncate, u, header)
+	result.url = u
+	result.duration = time.Since(begin)
+	observeFetch(&result, result.duration)
+	return result
+}
+
+func (f *Fetcher) fetchNoMetrics(ctx context.Context, maxLength int64, truncate bool, u *url.URL, header http.Header) fetchResult {
+	if h := u.Host; strings.LastIndex(h, ":") > strings.LastIndex(h, "]") {
+		u.Host = strings.TrimSuffix(h, ":")
+	}
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("User-Agent", f.UserAgent)
+	if header.Get("Accept") == "" {
+		header.Set("Accept", fetcherAccept)
+	}
+
+	requestContext, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timedOut := atomic.Bool{}
+	timer := (*time.Timer)(nil)
+	setTimeout := func(d time.Duration) {
+		if timer != nil {
+			timer.Stop()
+		}
+		if d > 0 {
+			timer = time.AfterFunc(d, func() {
+				timedOut.Store(true)
+				cancel()
+			})
+		}
+	}
+	defer setTimeout(0)
+
+	request := (&http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       u.Host,
+	}).WithContext(requestContext)
+
+	setTimeout(f.HeaderTimeout)
+	response, err := f.RoundTripper.RoundTrip(request)
+	if err != nil {
+		if timedOut.Load() {
+			return fetchResult{err: true, errType: crawldatabase.TypeErrorTimeout}
+		}
+		return fetchResult{err: true, errType: networkErrorType(err)}
+	}
+	defer response.Body.Close()
+	setTimeout(f.BodyTimeout)
+
+	return readResponse(u, response, maxLength, truncate, &timedOut)
+}
+
+// Convert the response into a fetchResult, read the body only for a 2xx
+// response. A body (or a Content-Length) bigger than maxLength is an error
+// TypeErrorTooLarge, except if truncate: the body is truncated. If timedOut is true after a body read error, the error type is
+// TypeErrorTimeout.
+func readResponse(u *url.URL, response *http.Response, maxLength int64, truncate bool, timedOut *atomic.Bool) fetchResult {
+	result := fetchResult{
+		url:             u,
+		status:          response.StatusCode,
+		etag:            response.Header.Get("ETag"),
+		lastModified:    response.Header.Get("Last-Modified"),
+		contentType:     response.Header.Get("Content-Type"),
+		canonical:       getLinkCanonical(u, response.Header.Values("Link")),
+		robotsTag:       response.Header.Values("X-Robots-Tag"),
+		contentLanguage: response.Header.Get("Content-Language"),
+		cacheControl:    response.Header.Get("Cache-Control"),
+	}
+
+	if response.StatusCode == http.StatusNotModified {
+		result.notModified = true
+		return result
+	} else if code := response.StatusCode / 100; code == 3 {
+		result.redirect = getLocation(u, response)
+		if result.redirect == nil {
+			result.err = true
+			result.errType = crawldatabase.HTTPErrorType(response.StatusCode)
+		}
+		return result
+	} else if code != 2 {
+		result.err = true
+		result.errType = crawldatabase.HTTPErrorType(response.StatusCode)
+		if s := response.StatusCode; s == http.StatusTooManyRequests || s == http.StatusServiceUnavailable {
+			result.retryAfter = parseRetryAfter(response.Header.Get("Retry-After"), time.Now())
+		}
+		return result
+	}
+
+	if response.ContentLength > maxLength && !truncate {
+		result.err = true
+		result.errType = crawldatabase.TypeErrorTooLarge
+		return result
+	}
+
+	buff := common.GetBuffer()
+	if l := response.ContentLength; l > 0 && l < maxLength {
+		buff.Grow(int(l))
+	} else {
+		buff.Grow(int(maxLength))
+	}
+	if _, err := buff.ReadFrom(io.LimitReader(response.Body, maxLength+1)); err != nil {
+		common.RecycleBuffer(buff)
+		errType := networkErrorType(err)
+		if timedOut.Load() {
+			errType = crawldatabase.TypeErrorTimeout
+		}
+		return fetchResult{err: true, errType: errType, url: u, status: response.StatusCode}
+	}
+	if int64(buff.Len()) > maxLength && !truncate {
+		common.RecycleBuffer(buff)
+		result.err = true
+		result.errType = crawldatabase.TypeErrorTooLarge
+		return result
+	} else if int64(buff.Len()) > maxLength {
+		result.truncated = true
+		buff.Truncate(int(maxLength))
+	}
+
+	result.body = buff
+	return result
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFetcherError(t *testing.T) {
+	fetcher := Fetcher{RoundTripper: mapRoundTripper{"https://example.org/big": []byte("0123456789")}}
+
+	result := fetcher.fetch(context.Background(), 5, common.ParseURL("https://example.org/big"), nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorTooLarge, result.errType)
+
+	result = fetcher.fetch(context.Background(), 5, common.ParseURL("https://example.org/404"), nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorNotFound, result.errType)
+	assert.Equal(t, 404, result.status)
+}
+
+func TestFetcherRequest(t *testing.T) {
+	roundTripper := &slowRoundTripper{}
+	fetcher := Fetcher{RoundTripper: roundTripper, UserAgent: "IstyTest/1.0"}
+	header := http.Header{"If-None-Match": []string{`"v1"`}}
+
+	result := fetcher.fetch(context.Background(), 15, common.ParseURL("https://example.org/"), header)
+	assert.False(t, result.err)
+	assert.Equal(t, "Hello", result.body.String())
+	assert.Equal(t, http.Header{
+		"User-Agent":    []string{"IstyTest/1.0"},
+		"Accept":        []string{fetcherAccept},
+		"If-None-Match": []string{`"v1"`},
+	}, roundTripper.request.Header)
+	assert.Equal(t, http.Header{"If-None-Match": []string{`"v1"`}}, header)
+	assert.Equal(t, "example.org", roundTripper.request.Host)
+	assert.NotEqual(t, context.Background(), roundTripper.request.Context())
+}
+
+func TestFetcherResponse(t *testing.T) {
+	u := common.ParseURL("https://example.org/")
+	fetcher := Fetcher{RoundTripper: &slowRoundTripper{}}
+
+	result := fetcher.fetchMeasured(context.Background(), 3, true, u, nil)
+	assert.False(t, result.err)
+	assert.Equal(t, "Hel", result.body.String())
+	response := result.pageResponse()
+	assert.NotZero(t, response.Duration)
+	response.Duration = 0
+	assert.Equal(t, Response{
+		Status:    200,
+		URL:       *u,
+		BodySize:  3,
+		Truncated: true,
+	}, response)
+
+	result = fetcher.fetch(context.Background(), 5, u, nil)
+	assert.Equal(t, "Hello", result.body.String())
+	assert.False(t, result.truncated)
+
+	// Too large without Content-Length
+	result = fetcher.fetch(context.Background(), 3, u, nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorTooLarge, result.errType)
+	assert.Nil(t, result.body)
+}
+
+func TestFetcherTimeout(t *testing.T) {
+	u := common.ParseURL("https://example.org/")
+
+	// Header
+	fetcher := Fetcher{RoundTripper: &slowRoundTripper{headerDelay: time.Hour}, HeaderTimeout: time.Millisecond}
+	result := fetcher.fetch(context.Background(), 15, u, nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorTimeout, result.errType)
+
+	// Body
+	fetcher = Fetcher{RoundTripper: &slowRoundTripper{bodyDelay: time.Hour}, HeaderTimeout: time.Hour, BodyTimeout: time.Millisecond}
+	result = fetcher.fetch(context.Background(), 15, u, nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorTimeout, result.errType)
+	assert.Equal(t, 200, result.status)
+
+	// Canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fetcher = Fetcher{RoundTripper: &slowRoundTripper{headerDelay: time.Hour}}
+	result = fetcher.fetch(ctx, 15, u, nil)
+	assert.True(t, result.err)
+	assert.Equal(t, crawldatabase.TypeErrorNetwork, result.errType)
+}
+
+// A round tripper that wait the delays or the request context, and
+// respond "Hello".
+type slowRoundTripper struct {
+	headerDelay, bodyDelay time.Duration
+	request                *http.Request
+}
+
+func (r *slowRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.request = request
+	if err := waitContext(request.Context(), r.headerDelay); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(readerFunc(func(p []byte) (int, error) {
+			if err := waitContext(request.Context(), r.bodyDelay); err != nil {
+				return 0, err
+			}
+			return copy(p, "Hello"), io.EOF
+		})),
+		Request: request,
+	}, nil
+}
+
+func waitContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
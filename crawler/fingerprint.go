@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/crawler/simhash"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"sync"
+)
+
+// The number of bands of 16 bits in a fingerprint. Two near fingerprints
+// (distance <= simhash.MaxDistance) have at least one same band.
+const fingerprintBands = 4
+
+// An index of the SimHash of the HTML pages, to find near-duplicate. It's
+// loaded from the database at the first use.
+type fingerprintIndex struct {
+	db *crawldatabase.Database[Page]
+
+	mutex  sync.Mutex
+	loaded bool
+	bands  [fingerprintBands]map[uint16][]fingerprintItem
+	// The hash of each page in the bands.
+	hashes map[keys.Key]uint64
+}
+
+type fingerprintItem struct {
+	key  keys.Key
+	hash uint64
+}
+
+func newFingerprintIndex(db *crawldatabase.Database[Page]) *fingerprintIndex {
+	index := &fingerprintIndex{
+		db:     db,
+		hashes: make(map[keys.Key]uint64),
+	}
+	for i := range index.bands {
+		index.bands[i] = make(map[uint16][]fingerprintItem)
+	}
+	return index
+}
+
+// Add the fingerprint of the HTML pages from the database, only once.
+// For old pages without fingerprint, compute it. index.mutex must be locked.
+func (index *fingerprintIndex) load() error {
+	if index.loaded || index.db == nil {
+		return nil
+	}
+	index.loaded = true
+	if index.db.CountHTML() == 0 {
+		return nil
+	}
+	return index.db.ForHTML(func(key keys.Key, page *Page) {
+		hash := page.SimHash
+		if hash == 0 && page.Html != nil {
+			hash = pageSimHash(page.Html)
+		}
+		index.set(key, hash)
+	})
+}
+
+// Set the fingerprint of the page key, it replaces the previous one. Zero
+// hash only removes it. index.mutex must be locked.
+func (index *fingerprintIndex) set(key keys.Key, hash uint64) {
+	if previous, ok := index.hashes[key]; ok {
+		if previous == hash {
+			return
+		}
+		delete(index.hashes, key)
+		for i, band := range index.bands {
+			b := uint16(previous >> (16 * i))
+			items := band[b]
+			for j, item := range items {
+				if item.key == key {
+					items = append(items[:j], items[j+1:]...)
+					break
+				}
+			}
+			if len(items) == 0 {
+				delete(band, b)
+			} else {
+				band[b] = items
+			}
+		}
+	}
+
+	if hash == 0 {
+		return
+	}
+	index.hashes[key] = hash
+	for i, band := range index.bands {
+		b := uint16(hash >> (16 * i))
+		band[b] = append(band[b], fingerprintItem{key, hash})
+	}
+}
+
+// Get the first page near the hash (except the page key) accepted by
+// isOriginal, the fingerprint of the page key is removed. If none, set the
+// fingerprint of the page key and return false. It's atomic, so of two
+// near pages, only one is an original.
+func (index *fingerprintIndex) original(key keys.Key, hash uint64, isOriginal func(keys.Key) bool) (keys.Key, bool, error) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	if err := index.load(); err != nil {
+		return keys.Key{}, false, err
+	}
+
+	if hash != 0 {
+		for i, band := range index.bands {
+			for _, item := range band[uint16(hash>>(16*i))] {
+				if item.key != key && simhash.Near(item.hash, hash) && isOriginal(item.key) {
+					index.set(key, 0)
+					return item.key, true, nil
+				}
+			}
+		}
+	}
+
+	index.set(key, hash)
+	return keys.Key{}, false, nil
+}
+
+// Compute the SimHash of the visible text of the page.
+func pageSimHash(root *htmlnode.Root) uint64 {
+	words := []string(nil)
+	root.Body.Visit(func(node htmlnode.Node) {
+		words = append(words, simhash.Words(node.Text)...)
+	})
+	return simhash.Sum(words)
+}
+
+// Save the key as an alias of a near-duplicate HTML page, and return true.
+// If no duplicate, add the hash to the index and return false.
+func (ctx *fetchContext) saveDuplicate(key keys.Key, hash uint64) bool {
+	original, found, err := ctx.fingerprints.original(key, hash, func(original keys.Key) bool {
+		// The original can be changed to an other type (alias, error...).
+		return ctx.db.GetType(original) == crawldatabase.TypeFileHTML
+	})
+	if err != nil {
+		ctx.logger.Warn("crawl.fingerprints", "err", err.Error())
+		return false
+	} else if !found {
+		return false
+	}
+	ctx.db.SetAlias(key, original, crawldatabase.TypeAliasDuplicate)
+	return true
+}
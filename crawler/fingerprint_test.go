@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const fingerprintArticle = `The municipal council met on Monday evening to discuss the renovation
+of the public library. The project includes a new reading room, a larger
+children's area and the replacement of the heating system. The mayor said
+the works should begin in the spring and last about eighteen months. During
+this period, a temporary library will open in the former post office, with
+reduced opening hours and a smaller collection of books.`
+
+func TestFingerprintIndex(t *testing.T) {
+	index := newFingerprintIndex(nil)
+	a, b, c := keys.NewString("a"), keys.NewString("b"), keys.NewString("c")
+	all := func(keys.Key) bool { return true }
+	none := func(keys.Key) bool { return false }
+	test := func(key keys.Key, hash uint64, isOriginal func(keys.Key) bool, expected keys.Key) {
+		t.Helper()
+		original, found, err := index.original(key, hash, isOriginal)
+		assert.NoError(t, err)
+		assert.Equal(t, expected != keys.Key{}, found)
+		assert.Equal(t, expected, original)
+	}
+
+	test(a, 0b1111, all, keys.Key{})
+	test(b, 0xFFFF_0000_0000_0000, all, keys.Key{})
+	test(a, 0b1111, all, keys.Key{})
+	test(c, 0, all, keys.Key{})
+	test(c, 0b0111, all, a)
+	test(c, 0xFFFF_0000_0000_0001, all, b)
+	test(c, 0xFFFF_0000_0000_000F, none, keys.Key{})
+	assert.Equal(t, map[keys.Key]uint64{
+		a: 0b1111,
+		b: 0xFFFF_0000_0000_0000,
+		c: 0xFFFF_0000_0000_000F,
+	}, index.hashes)
+
+	// The page a is changed, its old hash is replaced.
+	test(a, 0x0F0F_0F0F_0F0F_0F0F, all, keys.Key{})
+	test(c, 0b0111, all, keys.Key{})
+	assert.Empty(t, index.bands[0][0b1111])
+	// The page c is now a duplicate, it's not an original.
+	test(c, 0x0F0F_0F0F_0F0F_0F0E, all, a)
+	assert.NotContains(t, index.hashes, c)
+}
+
+func TestCrawlDuplicate(t *testing.T) {
+	logger := slog.New(sloghandlers.NewNullHandler())
+	_, db, _ := crawldatabase.OpenMemory[Page](logger, "", false)
+	crawl := func(input string, roundTripper mapRoundTripper) {
+		assert.NoError(t, Crawl(context.Background(), Config{
+			DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+				return nil, db, nil
+			},
+			Input:     common.ParseURLs(input),
+			MaxLength: 15_000,
+			MaxGo:     1,
+			Fetcher:   Fetcher{RoundTripper: roundTripper},
+			Logger:    logger,
+		}))
+	}
+
+	crawl("https://example.org/", mapRoundTripper{
+		"https://example.org/":      []byte(`<!DOCTYPE html><body><a href="/print">print</a>` + fingerprintArticle),
+		"https://example.org/print": []byte(`<!DOCTYPE html><body><a href="/print">print</a>` + strings.Replace(fingerprintArticle, "Monday", "Tuesday", 1)),
+	})
+	assert.Equal(t, 1, db.CountHTML())
+	assert.Equal(t, map[keys.Key]keys.Key{
+		keys.NewString("https://example.org/print"): keys.NewString("https://example.org/"),
+	}, db.Redirections())
+
+	// The index is loaded from the DB.
+	crawl("https://mirror.example.org/", mapRoundTripper{
+		"https://mirror.example.org/": []byte(`<!DOCTYPE html><body><a href="/print">print</a>` + fingerprintArticle),
+	})
+	assert.Equal(t, 1, db.CountHTML())
+	assert.Equal(t, crawldatabase.TypeAliasDuplicate, db.GetType(keys.NewString("https://mirror.example.org/")))
+}
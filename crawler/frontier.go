@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"container/heap"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/url"
+	"time"
+)
+
+// The maximum number of URLs given to a worker, then the worker choose again
+// the best host.
+const frontierBatch = 16
+
+// A URL to crawl.
+type frontierItem struct {
+	url *url.URL
+	key keys.Key
+	// The depth from the seeds.
+	depth int
+	// The number of links to this URL found while it's in the frontier.
+	inbound int
+	// The index in the host queue.
+	index int
+}
+
+// The priority of the item, greater is first.
+func (item *frontierItem) priority(weight float64) float64 {
+	return weight * float64(item.inbound+1) / float64(item.depth+1)
+}
+
+// A heap of frontierItem, the best item is the first.
+type frontierQueue []*frontierItem
+
+func (q frontierQueue) Len() int { return len(q) }
+func (q frontierQueue) Less(i, j int) bool {
+	pi, pj := q[i].priority(1), q[j].priority(1)
+	if pi != pj {
+		return pi > pj
+	}
+	return q[i].key.Less(&q[j].key)
+}
+func (q frontierQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *frontierQueue) Push(x any) {
+	item := x.(*frontierItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+func (q *frontierQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+// All URLs of a host to crawl.
+type host struct {
+	scheme string
+	host   string
+	// The weight of the host, see Config.HostWeight.
+	weight float64
+
+	queue  frontierQueue
+	queued map[keys.Key]*frontierItem
+
+	state    HostState
+	fetching bool
+	// The last crawl delay used, zero before the first batch.
+	delay time.Duration
+}
+
+func newHost(scheme, hostname string, weight float64) *host {
+	return &host{
+		scheme: scheme,
+		host:   hostname,
+		weight: weight,
+		queued: make(map[keys.Key]*frontierItem),
+	}
+}
+
+// Add the URL in the queue.
+func (h *host) push(key keys.Key, u *url.URL, depth int) {
+	h.pushItem(&frontierItem{
+		url:   u,
+		key:   key,
+		depth: depth,
+	})
+}
+
+// Add the item in the queue.
+func (h *host) pushItem(item *frontierItem) {
+	h.queued[item.key] = item
+	heap.Push(&h.queue, item)
+}
+
+// Increment the inbound count of the URL if it's queued.
+func (h *host) incInbound(key keys.Key) {
+	if item := h.queued[key]; item != nil {
+		item.inbound++
+		heap.Fix(&h.queue, item.index)
+	}
+}
+
+// Remove and return the n best items.
+func (h *host) pop(n int) []*frontierItem {
+	if n > len(h.queue) {
+		n = len(h.queue)
+	}
+	items := make([]*frontierItem, n)
+	for i := range items {
+		items[i] = heap.Pop(&h.queue).(*frontierItem)
+		delete(h.queued, items[i].key)
+	}
+	return items
+}
+
+// Remove all URLs from the queue.
+func (h *host) clear() {
+	h.queue = nil
+	h.queued = make(map[keys.Key]*frontierItem)
+}
+
+// The priority of the best URL of the host.
+func (h *host) priority() float64 {
+	if len(h.queue) == 0 {
+		return 0
+	}
+	return h.queue[0].priority(h.weight)
+}
+
+// A batch of URLs from a host, crawled by a worker.
+type batch struct {
+	scheme string
+	host   string
+	items  []*frontierItem
+	state  HostState
+	// The crawl delay, see fetchContext.delay().
+	delay time.Duration
+
+	// If not zero, the worker has no URL and wait until this instant.
+	wait time.Time
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHostPop(t *testing.T) {
+	h := newHost("https", "example.org", 1)
+	push := func(s string, depth int) keys.Key {
+		u := common.ParseURL(s)
+		key := keys.NewURL(u)
+		h.push(key, u, depth)
+		return key
+	}
+	push("https://example.org/deep", 3)
+	linked := push("https://example.org/linked", 2)
+	push("https://example.org/", 0)
+	push("https://example.org/dir/", 1)
+
+	h.incInbound(linked)
+	h.incInbound(linked)
+	h.incInbound(linked)
+	h.incInbound(keys.NewString("https://example.org/unknown"))
+
+	popped := []string{}
+	for _, item := range h.pop(10) {
+		popped = append(popped, item.url.String())
+	}
+	assert.Equal(t, []string{
+		"https://example.org/linked",
+		"https://example.org/",
+		"https://example.org/dir/",
+		"https://example.org/deep",
+	}, popped)
+	assert.Empty(t, h.queued)
+	assert.Zero(t, h.priority())
+}
+
+func TestTryChooseWork(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	ctx := &fetchContext{
+		db:              db,
+		hosts:           make(map[string]*host),
+		parked:          make(map[string]bool),
+		hostsPlanned:    make(map[string]int),
+		hostWeight:      map[string]float64{"important.org": 10},
+		maxPagesPerHost: 20,
+		maxGo:           0,
+	}
+
+	urls := make(map[keys.Key]*url.URL)
+	for i := 0; i < 30; i++ {
+		for _, h := range [...]string{"example.org", "important.org"} {
+			u := &url.URL{Scheme: "https", Host: h, Path: "/" + string(rune('a'+i))}
+			urls[keys.NewURL(u)] = u
+		}
+	}
+	ctx.planURLs(urls, 1)
+
+	b := ctx.tryChooseWork(nil)
+	assert.Equal(t, "important.org", b.host)
+	assert.Len(t, b.items, frontierBatch)
+
+	b = ctx.tryChooseWork(nil)
+	assert.Equal(t, "example.org", b.host)
+	assert.Len(t, b.items, frontierBatch)
+
+	// The budget of important.org is reached.
+	b = ctx.tryChooseWork(&batch{scheme: "https", host: "important.org"})
+	assert.Equal(t, "important.org", b.host)
+	assert.Len(t, b.items, 20-frontierBatch)
+	assert.Empty(t, ctx.hosts["https:important.org"].queue)
+
+	ctx.planURLs(urls, 1)
+	assert.Empty(t, ctx.hosts["https:important.org"].queue)
+
+	// Only the popped URLs are charged, the not fetched URLs are refunded.
+	one := &url.URL{Scheme: "https", Host: "one.org", Path: "/"}
+	ctx.planURLs(map[keys.Key]*url.URL{keys.NewURL(one): one}, 1)
+	b = ctx.tryChooseWork(nil)
+	assert.Equal(t, "one.org", b.host)
+	assert.Equal(t, 1, ctx.hostsPlanned["https:one.org"])
+	b = ctx.tryChooseWork(b)
+	assert.Equal(t, "one.org", b.host)
+	assert.Len(t, b.items, 1)
+	assert.Equal(t, 1, ctx.hostsPlanned["https:one.org"])
+}
+
+func TestCrawlMaxDepth(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return nil, db, nil
+		},
+		Input:     common.ParseURLs("https://example.org/"),
+		MaxLength: 15_000,
+		MaxGo:     1,
+		MaxDepth:  1,
+		Fetcher: Fetcher{RoundTripper: mapRoundTripper{
+			"https://example.org/":       []byte(`<!DOCTYPE html><a href="/1.html">1</a>`),
+			"https://example.org/1.html": []byte(`<!DOCTYPE html><a href="/2.html">2</a>`),
+			"https://example.org/2.html": []byte(`<!DOCTYPE html>`),
+		}},
+		Logger: slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	foundURL := []string{}
+	assert.NoError(t, Process(db, ProcessFunc(func(page *Page) {
+		foundURL = append(foundURL, page.URL.String())
+	})))
+	sort.Strings(foundURL)
+	assert.Equal(t, []string{
+		"https://example.org/",
+		"https://example.org/1.html",
+	}, foundURL)
+	assert.Equal(t, crawldatabase.TypeNothing, db.GetType(keys.NewString("https://example.org/2.html")))
+}
+
+func TestCrawlMaxDepthRestart(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	known := common.ParseURL("https://example.org/1.html")
+	assert.NoError(t, db.AddURL(map[keys.Key]*url.URL{keys.NewURL(known): known}, map[keys.Key]crawldatabase.Origin{
+		keys.NewURL(known): {Source: common.ParseURL("https://example.org/"), Depth: 1},
+	}))
+
+	// The known URL keeps its depth, its links are too deep.
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return []*url.URL{known}, db, nil
+		},
+		MaxLength: 15_000,
+		MaxGo:     1,
+		MaxDepth:  1,
+		Fetcher: Fetcher{RoundTripper: mapRoundTripper{
+			"https://example.org/1.html": []byte(`<!DOCTYPE html><a href="/2.html">2</a>`),
+			"https://example.org/2.html": []byte(`<!DOCTYPE html>`),
+		}},
+		Logger: slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	assert.Equal(t, crawldatabase.TypeFileHTML, db.GetType(keys.NewURL(known)))
+	assert.Equal(t, crawldatabase.TypeNothing, db.GetType(keys.NewString("https://example.org/2.html")))
+}
+
+func TestHostLimits(t *testing.T) {
+	ctx := &fetchContext{
+		maxLength:     1000,
+		minCrawlDelay: time.Second,
+		maxCrawlDelay: time.Second * 10,
+		hostLimits: map[string]HostLimits{
+			"slow.org":  {MinCrawlDelay: time.Second * 5},
+			"small.org": {MaxLength: 10},
+		},
+	}
+	assert.Equal(t, time.Second, ctx.delay("example.org", 0))
+	assert.Equal(t, time.Second*10, ctx.delay("example.org", 60))
+	assert.Equal(t, time.Second*5, ctx.delay("slow.org", 0))
+	assert.Equal(t, time.Second*10, ctx.delay("slow.org", 60))
+	assert.Equal(t, int64(1000), ctx.hostMaxLength("example.org"))
+	assert.Equal(t, int64(10), ctx.hostMaxLength("small.org"))
+}
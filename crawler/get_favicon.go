@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"bytes"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The max size of a favicon, bigger file are ignored.
+const maxFaviconLength = 100_000
+
+// A favicon of a host.
+type Favicon struct {
+	// The mime type, begin with "image/".
+	Type string
+	Data []byte
+}
+
+// Get the key of the favicon of the host, it's the key of "/favicon.ico"
+// even if the icon come from a <link rel=icon>.
+func FaviconKey(scheme, host string) keys.Key {
+	return keys.NewURL(&url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   faviconPath,
+	})
+}
+
+// Fetch and save the favicon of the host, only if it's unknown.
+// The icon is get from the home page (<link rel=icon>) if it's allowed by
+// the URL filters and the robots.txt, else from "/favicon.ico".
+//
+// Return the fetch result for the host state, zero if nothing is fetched
+// from the host. A host error is not saved, the favicon is fetched again
+// at the next crawl of the host.
+func (ctx *fetchContext) fetchFavicon(scheme, host string, crawDelay int) fetchResult {
+	key := FaviconKey(scheme, host)
+	switch ctx.db.GetType(key) {
+	case crawldatabase.TypeNothing, crawldatabase.TypeKnow:
+	default:
+		return fetchResult{}
+	}
+
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   faviconPath,
+	}
+	if home, _, _ := ctx.db.GetValue(keys.NewURL(&url.URL{Scheme: scheme, Host: host, Path: "/"})); home != nil && home.Html != nil {
+		if icon := home.Html.Meta.Icon; icon.String() != "" {
+			if iconURL := absoluteURL(&home.URL, &icon); iconURL != nil && ctx.allowFavicon(iconURL) {
+				u = iconURL
+			}
+		}
+	}
+
+	ctx.sleep(host, crawDelay)
+	if !ctx.setInFlight(createKey(scheme, host), u) {
+		return fetchResult{}
+	}
+	result := ctx.fetcher.fetchMultiple(ctx.context, maxFaviconLength, cloneURL(u), nil)
+	switch {
+	case result.body != nil:
+		ctx.saveFavicon(key, u, result.body.Bytes(), result.pageResponse())
+		common.RecycleBuffer(result.body)
+		result.body = nil
+	case result.hostError():
+		if u.Host != host {
+			// Not an error of this host.
+			return fetchResult{}
+		}
+	case result.err:
+		ctx.db.SetError(key, result.errType, result.status)
+	default:
+		// Too many redirections
+		ctx.db.SetError(key, crawldatabase.HTTPErrorType(result.status), result.status)
+	}
+
+	return result
+}
+
+// The favicon URL from the home page pass the URL filters and is allowed
+// by the robots.txt of its host, like a page.
+func (ctx *fetchContext) allowFavicon(u *url.URL) bool {
+	for _, filter := range ctx.filterURL {
+		if filter(u) {
+			return false
+		}
+	}
+	robots, unreachable := robotGet(ctx.context, ctx.db, u.Scheme, u.Host, ctx.fetcher)
+	return unreachable == nil && robots.Allow(u)
+}
+
+// Save the favicon data from u, or a parsing error if it's truncated or
+// it's not an image. The data is copied.
+func (ctx *fetchContext) saveFavicon(key keys.Key, u *url.URL, data []byte, response Response) {
+	if response.Truncated || len(data) > maxFaviconLength {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	}
+
+	mime := faviconType(data)
+	if mime == "" {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	}
+
+	ctx.db.SetValue(key, &Page{
+		URL: *u,
+		Favicon: &Favicon{
+			Type: mime,
+			Data: append([]byte(nil), data...),
+		},
+		Response: response,
+	}, crawldatabase.TypeFileFavicon)
+}
+
+// Get the mime type of the image, or empty string if it's not an image.
+func faviconType(data []byte) string {
+	mime := http.DetectContentType(data)
+	if strings.HasPrefix(mime, "image/") {
+		return mime
+	} else if bytes.Contains(data, []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return ""
+}
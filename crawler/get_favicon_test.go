@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestFetchFavicon(t *testing.T) {
+	faviconData, _ := fs.ReadFile(testdata, "testdata/favicon.ico")
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	newContext := func(roundTripper http.RoundTripper) *fetchContext {
+		return newFetchContext(context.Background(), Config{
+			FilterURL: []func(*url.URL) bool{
+				func(u *url.URL) bool { return u.Host == "cdn.example.com" },
+			},
+			Fetcher: Fetcher{RoundTripper: roundTripper},
+			Logger:  slog.New(sloghandlers.NewNullHandler()),
+		}, db)
+	}
+	setHome := func(host, icon string) {
+		root, err := htmlnode.Parse([]byte(`<!DOCTYPE html><link rel=icon href="` + icon + `">`))
+		assert.NoError(t, err)
+		home := url.URL{Scheme: "https", Host: host, Path: "/"}
+		assert.NoError(t, db.SetValue(keys.NewURL(&home), &Page{URL: home, Html: root}, crawldatabase.TypeFileHTML))
+	}
+	getFavicon := func(host string) *Page {
+		page, _, err := db.GetValue(FaviconKey("https", host))
+		assert.NoError(t, err)
+		return page
+	}
+
+	ctx := newContext(mapRoundTripper{
+		"https://a.example.org/robots.txt":       []byte("User-agent: *\nDisallow: /private/\n"),
+		"https://a.example.org/private/icon.ico": faviconData,
+		"https://a.example.org/favicon.ico":      faviconData,
+		"https://b.example.org/favicon.ico":      faviconData,
+		"https://c.example.org/icon.ico":         faviconData,
+		"https://cdn.example.com/icon.ico":       faviconData,
+	})
+
+	// Icon disallowed by the robots.txt
+	setHome("a.example.org", "/private/icon.ico")
+	ctx.fetchFavicon("https", "a.example.org", 0)
+	assert.Equal(t, "/favicon.ico", getFavicon("a.example.org").URL.Path)
+
+	// Icon filtered
+	setHome("b.example.org", "https://cdn.example.com/icon.ico")
+	ctx.fetchFavicon("https", "b.example.org", 0)
+	assert.Equal(t, "b.example.org", getFavicon("b.example.org").URL.Host)
+
+	// Allowed icon
+	setHome("c.example.org", "/icon.ico")
+	ctx.fetchFavicon("https", "c.example.org", 0)
+	assert.Equal(t, "/icon.ico", getFavicon("c.example.org").URL.Path)
+
+	// Paused host
+	ctx.paused = true
+	ctx.fetchFavicon("https", "d.example.org", 0)
+	assert.Equal(t, crawldatabase.TypeNothing, db.GetType(FaviconKey("https", "d.example.org")))
+
+	// A host error is not saved
+	ctx = newContext(statusRoundTripper(http.StatusServiceUnavailable))
+	result := ctx.fetchFavicon("https", "e.example.org", 0)
+	assert.True(t, result.hostError())
+	assert.Equal(t, crawldatabase.TypeNothing, db.GetType(FaviconKey("https", "e.example.org")))
+
+	// Not found
+	ctx = newContext(statusRoundTripper(http.StatusNotFound))
+	result = ctx.fetchFavicon("https", "e.example.org", 0)
+	assert.False(t, result.hostError())
+	assert.Equal(t, crawldatabase.TypeErrorNotFound, db.GetType(FaviconKey("https", "e.example.org")))
+}
+
+// Respond to all requests with the status.
+type statusRoundTripper int
+
+func (status statusRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: int(status),
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    request,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/feed"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/url"
+	"strings"
+)
+
+// Parse and save the feed, then add the link of all items.
+func (ctx *fetchContext) saveFeed(key keys.Key, u *url.URL, data []byte, depth int, response Response) {
+	f, err := feed.Parse(data)
+	if err != nil {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	}
+
+	urls := make(map[keys.Key]*url.URL, len(f.Items))
+	for i := range f.Items {
+		if link := absoluteURL(u, &f.Items[i].Link); link != nil {
+			urls[keys.NewURL(link)] = link
+		}
+	}
+	ctx.addURLs(u, urls, nil, depth+1)
+
+	ctx.db.SetValue(key, &Page{
+		URL:      *u,
+		Feed:     f,
+		Response: response,
+	}, crawldatabase.TypeFileRSS)
+}
+
+// Resolve the reference from the base, and clean it.
+// Return nil if the scheme is not http or https.
+func absoluteURL(base, ref *url.URL) *url.URL {
+	u := base.ResolveReference(ref)
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil
+	}
+	cleanURL(u)
+	u.Host = strings.TrimSuffix(u.Host, ".")
+	return u
+}
@@ -8,55 +8,212 @@ import (
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const robotsPath = "/robots.txt"
 const faviconPath = "/favicon.ico"
 
+// The robots.txt rules, see RFC 9309 sections 2.4 and 2.5.
+const (
+	// Only the begin of a bigger robots.txt is parsed.
+	robotsMaxLength = 500 * 1024
+	// The maximum duration of the cached robots.txt, also with a bigger
+	// Cache-Control max-age.
+	robotsCacheDuration = time.Hour * 24
+	// When the robots.txt is unreachable, the last good copy is used and
+	// the robots.txt is fetched again after this delay.
+	robotsRetryDelay = time.Hour
+	// After this unreachable duration, the robots.txt is unavailable.
+	robotsUnreachableDuration = time.Hour * 24 * 30
+)
+
+// The fetch history of a robots.txt.
+type RobotsFetch struct {
+	// The instant of the last good fetch: the robots.txt file (Page.Robots)
+	// or an unavailable robots.txt (4xx status). Zero if never.
+	Fetched time.Time
+	// The instant of the first unreachable fetch (5xx status, 429 status or
+	// network error) after Fetched. Zero if the last fetch is good.
+	Unreachable time.Time
+	// The instant of the next fetch.
+	Expires time.Time
+}
+
 // Get once the robots file. See robotGet for details.
-func robotGetter(ctx context.Context, db *crawldatabase.Database[Page], scheme, host string, roundTripper http.RoundTripper) func() *robotstxt.File {
+func robotGetter(ctx context.Context, db *crawldatabase.Database[Page], scheme, host string, fetcher *Fetcher) func() (*robotstxt.File, *fetchResult) {
 	robot := robotstxt.File{}
+	unreachable := (*fetchResult)(nil)
 	todo := true
-	return func() *robotstxt.File {
+	return func() (*robotstxt.File, *fetchResult) {
 		if todo {
 			todo = false
-			robot = robotGet(ctx, db, scheme, host, roundTripper)
+			robot, unreachable = robotGet(ctx, db, scheme, host, fetcher)
 		}
-		return &robot
+		return &robot, unreachable
 	}
 }
 
-// RobotGetter load it, or download and store it from the objectDB.
-// On error (from cache or when download), use robotstxt.DefaultRobots.
-// If cache is more than 24h, dowload it.
-func robotGet(ctx context.Context, db *crawldatabase.Database[Page], scheme, host string, roundTripper http.RoundTripper) robotstxt.File {
-	u := url.URL{
+// Get the robots.txt of the host from the database, or fetch it if it's
+// expired, following RFC 9309:
+//   - a 2xx response is parsed, only the first 500 KiB;
+//   - a 4xx response (except 429) or too many redirections means the
+//     robots.txt is unavailable, so all URLs are allowed
+//     (robotstxt.DefaultRobots);
+//   - a 5xx or 429 response or a network error means the robots.txt is
+//     unreachable: the last good copy is used during 30 days and fetched
+//     again each hour. Without copy, the robots.txt disallows all URLs: the
+//     fetch result is returned, the host must not be crawled now. After 30
+//     days, the robots.txt is unavailable.
+//
+// A good robots.txt is cached the Cache-Control max-age, between one hour
+// and 24 hours. Then it's revalidated with a conditional request, a 304
+// response keeps the stored copy.
+// The rules are the group of the fetcher User-Agent.
+func robotGet(ctx context.Context, db *crawldatabase.Database[Page], scheme, host string, fetcher *Fetcher) (robotstxt.File, *fetchResult) {
+	u := RobotsURL(scheme, host)
+	key := keys.NewURL(&u)
+	now := time.Now()
+
+	// Get from the DB
+	previous, date, _ := db.GetValue(key)
+	history := robotsHistory(previous, date)
+	if previous != nil && now.Before(history.Expires) {
+		if previous.Robots != nil {
+			return *previous.Robots, nil
+		}
+		return robotstxt.DefaultRobots, nil
+	}
+
+	result := fetcher.fetchMultiple(ctx, robotsMaxLength, cloneURL(&u), robotsConditionalHeader(previous))
+	page := robotsPage(u, previous, history, &result, fetcher.UserAgent, now)
+	db.SetValue(key, page, crawldatabase.TypeFileRobots)
+	if page.Robots == nil {
+		if !result.hostError() {
+			// A body read error is a network error for the host state.
+			result.status = 0
+		}
+		return robotstxt.File{}, &result
+	}
+
+	return *page.Robots, nil
+}
+
+// Get the fetch history of the stored robots.txt page (can be nil) saved
+// at date.
+func robotsHistory(previous *Page, date time.Time) RobotsFetch {
+	switch {
+	case previous == nil:
+		return RobotsFetch{}
+	case previous.RobotsFetch != nil:
+		return *previous.RobotsFetch
+	default:
+		// Saved before the fetch history.
+		return RobotsFetch{Fetched: date, Expires: date.Add(robotsCacheDuration)}
+	}
+}
+
+// Create the robots.txt page to store from the fetch result, see robotGet
+// for the rules. The previous page (can be nil) and its history are used
+// when the robots.txt is unreachable. The page Robots is nil if the
+// robots.txt is unreachable without good copy. The result body is recycled.
+func robotsPage(u url.URL, previous *Page, history RobotsFetch, result *fetchResult, userAgent string, now time.Time) *Page {
+	page := &Page{URL: u, Response: result.pageResponse()}
+
+	switch {
+	case result.notModified && previous != nil && previous.Robots != nil:
+		page = previous
+		page.RobotsFetch = &RobotsFetch{Fetched: now, Expires: now.Add(robotsCacheAge(result.cacheControl))}
+
+	case result.body != nil:
+		robots := robotstxt.Parse(result.body.Bytes(), userAgent)
+		common.RecycleBuffer(result.body)
+		result.body = nil
+		page.Robots = &robots
+		page.RobotsFetch = &RobotsFetch{Fetched: now, Expires: now.Add(robotsCacheAge(result.cacheControl))}
+
+	case result.redirect != nil || result.status/100 == 3 || robotsUnavailableStatus(result.status):
+		page.Robots = &robotstxt.File{}
+		page.RobotsFetch = &RobotsFetch{Fetched: now, Expires: now.Add(robotsCacheDuration)}
+
+	default:
+		unreachable := history.Unreachable
+		if unreachable.IsZero() {
+			unreachable = now
+		}
+
+		if now.Sub(unreachable) >= robotsUnreachableDuration {
+			page.Robots = &robotstxt.File{}
+			page.RobotsFetch = &RobotsFetch{Fetched: now, Expires: now.Add(robotsCacheDuration)}
+		} else if previous != nil && previous.Robots != nil && !history.Fetched.IsZero() {
+			// Keep the last good copy with its response.
+			page = previous
+			page.RobotsFetch = &RobotsFetch{
+				Fetched:     history.Fetched,
+				Unreachable: unreachable,
+				Expires:     now.Add(robotsRetryDelay),
+			}
+		} else {
+			// No copy, fetched again at the next crawl of the host.
+			page.RobotsFetch = &RobotsFetch{Fetched: history.Fetched, Unreachable: unreachable, Expires: now}
+		}
+	}
+
+	return page
+}
+
+// The URL of the robots.txt of the host, also used as database key.
+func RobotsURL(scheme, host string) url.URL {
+	return url.URL{
 		Scheme: scheme,
 		Host:   host,
 		Path:   robotsPath,
 	}
-	key := keys.NewURL(&u)
+}
 
-	// Get from the DB
-	if page, date, _ := db.GetValue(key); page != nil && time.Since(date) < time.Hour*24 {
-		if page.Robots != nil {
-			return *page.Robots
+// Get the cache duration from the Cache-Control header: the max-age,
+// bounded by robotsRetryDelay and robotsCacheDuration. The minimum for
+// no-store and no-cache, the robots.txt is revalidated after it.
+func robotsCacheAge(cacheControl string) time.Duration {
+	age := robotsCacheDuration
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return robotsRetryDelay
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err == nil && seconds >= 0 && time.Duration(seconds)*time.Second < age {
+				age = time.Duration(seconds) * time.Second
+			}
 		}
-		return robotstxt.DefaultRobots
 	}
+	if age < robotsRetryDelay {
+		return robotsRetryDelay
+	}
+	return age
+}
 
-	robots := robotstxt.DefaultRobots
-	if buff := fetchMultiple(ctx, roundTripper, 500_1024, &u, 5); buff != nil {
-		robots = robotstxt.Parse(buff.Bytes())
-		common.RecycleBuffer(buff)
+// Create the header to revalidate the stored robots.txt with the validators
+// of its response. Nil without good copy.
+func robotsConditionalHeader(previous *Page) http.Header {
+	if previous == nil || previous.Robots == nil {
+		return nil
 	}
 
-	db.SetValue(key, &Page{
-		URL:    u,
-		Robots: &robots},
-		crawldatabase.TypeFileRobots,
-	)
+	header := make(http.Header)
+	if etag := previous.Response.ETag; etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := previous.Response.LastModified; lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return header
+}
 
-	return robots
+// The robots.txt is unavailable (allow all) after this response status.
+func robotsUnavailableStatus(status int) bool {
+	return status/100 == 4 && status != http.StatusTooManyRequests
 }
@@ -1,24 +1,233 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
 	"github.com/HuguesGuilleus/isty-search/crawler/database"
 	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt"
 	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt/testdata"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetRobotstxt(t *testing.T) {
 	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
 
-	robots := *robotGetter(context.Background(), db, "https", "www.monde-diplomatique.fr", mapRoundTripper{
-		"https://www.monde-diplomatique.fr/robots.txt": robotstxttestdata.MondeDiplomatique,
+	robots, unreachable := robotGetter(context.Background(), db, "https", "www.monde-diplomatique.fr", &Fetcher{
+		RoundTripper: mapRoundTripper{
+			"https://www.monde-diplomatique.fr/robots.txt": robotstxttestdata.MondeDiplomatique,
+		},
+		UserAgent:   "Isty",
+		MaxRedirect: 5,
 	})()
-	assert.Equal(t, robotstxt.Parse(robotstxttestdata.MondeDiplomatique), robots)
+	assert.Nil(t, unreachable)
+	assert.Equal(t, robotstxt.Parse(robotstxttestdata.MondeDiplomatique, "Isty"), *robots)
+	page, _, err := db.GetValue(keys.NewString("https://www.monde-diplomatique.fr/robots.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, page.Response.Status)
+	assert.Equal(t, "https://www.monde-diplomatique.fr/robots.txt", page.Response.URL.String())
+	assert.Equal(t, len(robotstxttestdata.MondeDiplomatique), page.Response.BodySize)
 
-	robotsSecond := *robotGetter(context.Background(), db, "https", "www.monde-diplomatique.fr", mapRoundTripper{})()
+	robotsSecond, _ := robotGetter(context.Background(), db, "https", "www.monde-diplomatique.fr", &Fetcher{
+		RoundTripper: mapRoundTripper{},
+		UserAgent:    "Isty",
+		MaxRedirect:  5,
+	})()
 	// The assert package make a difference between empty slice and nil slice,
 	// so we test only CrawlDelay (type int).
-	assert.Equal(t, robotstxt.Parse(robotstxttestdata.MondeDiplomatique).CrawlDelay, robotsSecond.CrawlDelay)
+	assert.Equal(t, robotstxt.Parse(robotstxttestdata.MondeDiplomatique, "Isty").CrawlDelay, robotsSecond.CrawlDelay)
+}
+
+func TestGetRobotstxtStatus(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	roundTripper := &robotsRoundTripper{}
+	fetcher := &Fetcher{RoundTripper: roundTripper, UserAgent: "Isty", MaxRedirect: 5}
+	key := keys.NewString("https://example.org/robots.txt")
+	private := common.ParseURL("https://example.org/private")
+	get := func() (robotstxt.File, *fetchResult, *Page) {
+		t.Helper()
+		robots, unreachable := robotGet(context.Background(), db, "https", "example.org", fetcher)
+		page, _, err := db.GetValue(key)
+		assert.NoError(t, err)
+		return robots, unreachable, page
+	}
+	expire := func(page *Page, unreachable time.Time) {
+		page.RobotsFetch.Expires = time.Now().Add(-time.Second)
+		page.RobotsFetch.Unreachable = unreachable
+		assert.NoError(t, db.SetValue(key, page, crawldatabase.TypeFileRobots))
+	}
+
+	// Unreachable without good copy: disallow all, fetched again each time
+	roundTripper.status = http.StatusServiceUnavailable
+	_, unreachable, page := get()
+	assert.NotNil(t, unreachable)
+	assert.True(t, unreachable.hostError())
+	assert.Nil(t, page.Robots)
+	assert.Equal(t, http.StatusServiceUnavailable, page.Response.Status)
+	assert.WithinDuration(t, time.Now(), page.RobotsFetch.Unreachable, time.Second)
+	roundTripper.count = 0
+	_, unreachable, _ = get()
+	assert.NotNil(t, unreachable)
+	assert.Equal(t, 1, roundTripper.count)
+
+	// Unavailable: allow all
+	roundTripper.status = http.StatusNotFound
+	robots, unreachable, page := get()
+	assert.Nil(t, unreachable)
+	assert.True(t, robots.Allow(private))
+	assert.True(t, page.RobotsFetch.Unreachable.IsZero())
+	assert.WithinDuration(t, time.Now(), page.RobotsFetch.Fetched, time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour*24), page.RobotsFetch.Expires, time.Second)
+
+	// Success, cached with the max-age
+	expire(page, time.Time{})
+	roundTripper.status = http.StatusOK
+	roundTripper.cacheControl = "public, max-age=7200"
+	roundTripper.etag = `"v1"`
+	robots, unreachable, page = get()
+	assert.Nil(t, unreachable)
+	assert.False(t, robots.Allow(private))
+	assert.True(t, page.RobotsFetch.Unreachable.IsZero())
+	assert.WithinDuration(t, time.Now().Add(time.Hour*2), page.RobotsFetch.Expires, time.Second)
+	roundTripper.count = 0
+	robots, _, _ = get()
+	assert.False(t, robots.Allow(private))
+	assert.Equal(t, 0, roundTripper.count)
+
+	// Revalidated, not modified: the copy is kept
+	expire(page, time.Time{})
+	roundTripper.cacheControl = "no-cache"
+	robots, unreachable, page = get()
+	assert.Nil(t, unreachable)
+	assert.False(t, robots.Allow(private))
+	assert.Equal(t, 1, roundTripper.notModified)
+	assert.Equal(t, http.StatusOK, page.Response.Status)
+	assert.WithinDuration(t, time.Now(), page.RobotsFetch.Fetched, time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), page.RobotsFetch.Expires, time.Second)
+	roundTripper.etag = ""
+
+	// Unreachable with a good copy: the copy is used
+	expire(page, time.Time{})
+	fetched := page.RobotsFetch.Fetched
+	roundTripper.status = http.StatusInternalServerError
+	robots, unreachable, page = get()
+	assert.Nil(t, unreachable)
+	assert.False(t, robots.Allow(private))
+	assert.Equal(t, http.StatusOK, page.Response.Status)
+	assert.Equal(t, fetched, page.RobotsFetch.Fetched)
+	assert.WithinDuration(t, time.Now(), page.RobotsFetch.Unreachable, time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), page.RobotsFetch.Expires, time.Second)
+
+	// Unreachable since more than 30 days: allow all
+	expire(page, time.Now().Add(-time.Hour*24*31))
+	robots, unreachable, page = get()
+	assert.Nil(t, unreachable)
+	assert.True(t, robots.Allow(private))
+	assert.NotNil(t, page.Robots)
+
+	// Only the first 500 KiB are parsed
+	expire(page, time.Time{})
+	roundTripper.status = http.StatusOK
+	roundTripper.body = "User-agent: *\nDisallow: /private\n" + strings.Repeat("#", robotsMaxLength) + "\nDisallow: /\n"
+	robots, _, page = get()
+	assert.False(t, robots.Allow(private))
+	assert.True(t, robots.Allow(common.ParseURL("https://example.org/public")))
+	assert.True(t, page.Response.Truncated)
+}
+
+func TestRobotsUnreachableCrawl(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	roundTripper := &robotsRoundTripper{status: http.StatusServiceUnavailable}
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return nil, db, nil
+		},
+		Input:            common.ParseURLs("https://example.org/", "https://example.org/1"),
+		MaxLength:        15_000,
+		MaxGo:            1,
+		MaxHostErrors:    1,
+		HostParkDuration: time.Minute,
+		Fetcher:          Fetcher{RoundTripper: roundTripper},
+		Logger:           slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	// No page is fetched, the URLs are not striked.
+	assert.Equal(t, 0, roundTripper.pages)
+	assert.Equal(t, crawldatabase.TypeKnow, db.GetType(keys.NewString("https://example.org/")))
+	assert.Equal(t, crawldatabase.TypeKnow, db.GetType(keys.NewString("https://example.org/1")))
+	page, _, err := db.GetValue(HostKey("https", "example.org"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Host.Errors)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), page.Host.ParkedUntil, time.Second*5)
+}
+
+func TestRobotsCacheAge(t *testing.T) {
+	assert.Equal(t, time.Hour*24, robotsCacheAge(""))
+	assert.Equal(t, time.Hour*24, robotsCacheAge("max-age=604800"))
+	assert.Equal(t, time.Hour*2, robotsCacheAge(`public, max-age="7200"`))
+	assert.Equal(t, time.Hour, robotsCacheAge("max-age=60"))
+	assert.Equal(t, time.Hour, robotsCacheAge("no-cache"))
+	assert.Equal(t, time.Hour, robotsCacheAge("no-store"))
+	assert.Equal(t, time.Hour*24, robotsCacheAge("max-age=yolo"))
+}
+
+// Respond to the robots.txt with the status, the body and Cache-Control
+// header, and to the other pages with a HTML page. With an ETag, a request
+// with this ETag in If-None-Match gets a 304 response.
+type robotsRoundTripper struct {
+	status       int
+	body         string
+	cacheControl string
+	etag         string
+
+	mutex sync.Mutex
+	// Number of request of robots.txt and of the other pages.
+	count, pages int
+	// Number of 304 responses.
+	notModified int
+}
+
+func (r *robotsRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`<!DOCTYPE html><p>Hello</p>`)),
+		Request:    request,
+	}
+	if request.URL.Path != robotsPath {
+		r.pages++
+		return response, nil
+	}
+
+	r.count++
+	response.Header.Set("Cache-Control", r.cacheControl)
+	if r.etag != "" && request.Header.Get("If-None-Match") == r.etag {
+		r.notModified++
+		response.StatusCode = http.StatusNotModified
+		response.Body = io.NopCloser(bytes.NewReader(nil))
+		return response, nil
+	}
+	response.Header.Set("ETag", r.etag)
+	response.StatusCode = r.status
+	body := r.body
+	if body == "" {
+		body = "User-agent: *\nDisallow: /private\n"
+	}
+	response.Body = io.NopCloser(bytes.NewReader([]byte(body)))
+	response.ContentLength = int64(len(body))
+
+	return response, nil
 }
@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt"
+	"github.com/HuguesGuilleus/isty-search/crawler/sitemap"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"io"
+	"net/url"
+)
+
+const sitemapPath = "/sitemap.xml"
+
+// The depth of the sitemaps and of their URLs, also for the sitemaps of a
+// sitemap index: the pages only linked from nested sitemaps are not deeper.
+const sitemapDepth = 1
+
+// Get all sitemaps declared in the robots file, or "/sitemap.xml" if
+// the robots file do not declare any sitemap.
+func sitemapURLs(scheme, host string, robots *robotstxt.File) map[keys.Key]*url.URL {
+	urls := make(map[keys.Key]*url.URL, len(robots.SiteMap)+1)
+	for _, s := range robots.SiteMap {
+		u := cloneURL(&s)
+		cleanURL(u)
+		urls[keys.NewURL(u)] = u
+	}
+
+	if len(urls) == 0 {
+		u := &url.URL{
+			Scheme: scheme,
+			Host:   host,
+			Path:   sitemapPath,
+		}
+		urls[keys.NewURL(u)] = u
+	}
+
+	return urls
+}
+
+// Return true the first time for this host in the crawl, then false.
+// Used to add the sitemaps of a host once.
+func (ctx *fetchContext) firstSitemaps(scheme, host string) bool {
+	ctx.hostsMutex.Lock()
+	defer ctx.hostsMutex.Unlock()
+	hostKey := createKey(scheme, host)
+	if ctx.sitemapsAdded[hostKey] {
+		return false
+	}
+	ctx.sitemapsAdded[hostKey] = true
+	return true
+}
+
+// Parse and save the sitemap, then add all sub sitemaps and pages URL at
+// sitemapDepth.
+func (ctx *fetchContext) saveSitemap(key keys.Key, u *url.URL, data []byte, response Response) {
+	file, err := sitemap.Parse(data)
+	if err != nil {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	}
+
+	urls := make(map[keys.Key]*url.URL, len(file.Sitemaps)+len(file.URLs))
+	for _, list := range [...][]url.URL{file.Sitemaps, file.URLs} {
+		for i := range list {
+			if link := absoluteURL(u, &list[i]); link != nil {
+				urls[keys.NewURL(link)] = link
+			}
+		}
+	}
+	ctx.addURLs(u, urls, nil, sitemapDepth)
+
+	ctx.db.SetValue(key, &Page{
+		URL:      *u,
+		Sitemap:  file,
+		Response: response,
+	}, crawldatabase.TypeFileSitemap)
+}
+
+// If data is compressed with gzip, decompress it (limited to maxLength).
+// If data is not compressed, return nil buffer and nil error.
+func gunzip(data []byte, maxLength int64) (*bytes.Buffer, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return nil, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	buff := common.GetBuffer()
+	if _, err := buff.ReadFrom(io.LimitReader(reader, maxLength)); err != nil {
+		common.RecycleBuffer(buff)
+		return nil, err
+	}
+
+	return buff, nil
+}
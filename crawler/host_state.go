@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The state of a host, to slow down the crawl when the host fail.
+// It's saved in the database, see HostKey().
+type HostState struct {
+	// The number of consecutive errors: network error, 429 or 5xx status.
+	Errors int
+	// Do not fetch the host before this instant (backoff or Retry-After).
+	NotBefore time.Time
+	// The host is not crawled until this instant.
+	ParkedUntil time.Time
+}
+
+// Get the key of the host state in the database.
+func HostKey(scheme, host string) keys.Key {
+	return keys.NewString("host:" + createKey(scheme, host))
+}
+
+// Load the host state from the database, or return a zero state.
+func (ctx *fetchContext) loadHostState(scheme, host string) HostState {
+	page, _, _ := ctx.db.GetValue(HostKey(scheme, host))
+	if page == nil || page.Host == nil {
+		return HostState{}
+	}
+	return *page.Host
+}
+
+// Save the host state in the database.
+func (ctx *fetchContext) saveHostState(scheme, host string, state HostState) {
+	ctx.db.SetValue(HostKey(scheme, host), &Page{
+		URL:  url.URL{Scheme: scheme, Host: host},
+		Host: &state,
+	}, crawldatabase.TypeFileHost)
+}
+
+// Update the state after a fetch. Return true if the state changed.
+//
+// On error, the delay is the Retry-After header or the crawl delay doubled
+// for each consecutive error. After maxHostErrors consecutive errors or if
+// the delay is longer than hostParkDuration, the host is parked.
+func (ctx *fetchContext) updateHostState(scheme, host string, state *HostState, result *fetchResult, crawDelay int) bool {
+	if !result.hostError() {
+		if result.status == 0 || state.Errors == 0 {
+			return false
+		}
+		state.Errors = 0
+		return true
+	}
+
+	state.Errors++
+
+	delay := result.retryAfter
+	if delay == 0 {
+		delay = ctx.delay(host, crawDelay)
+		for i := 1; i < state.Errors && delay < ctx.hostParkDuration; i++ {
+			delay *= 2
+		}
+		if delay > ctx.hostParkDuration {
+			delay = ctx.hostParkDuration
+		}
+	}
+
+	now := time.Now()
+	state.NotBefore = now.Add(delay)
+	if state.Errors >= ctx.maxHostErrors || delay > ctx.hostParkDuration {
+		park := ctx.hostParkDuration
+		if delay > park {
+			park = delay
+		}
+		state.ParkedUntil = now.Add(park)
+		ctx.logger.Warn("host.park", "host", createKey(scheme, host), "errors", state.Errors, "until", state.ParkedUntil)
+	}
+
+	return true
+}
+
+// The result is an error from the host, not from the page.
+func (result *fetchResult) hostError() bool {
+	if !result.err {
+		return false
+	}
+	s := result.status
+	return s == 0 || s == http.StatusTooManyRequests || s >= 500
+}
+
+// Parse the Retry-After header (seconds or HTTP date). Return zero if the
+// value is invalid or in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
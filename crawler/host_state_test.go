@@ -0,0 +1,128 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostStatePark(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	roundTripper := &failRoundTripper{status: http.StatusServiceUnavailable}
+	crawl := func() {
+		assert.NoError(t, Crawl(context.Background(), Config{
+			DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+				return nil, db, nil
+			},
+			Input:            common.ParseURLs("https://example.org/", "https://example.org/1", "https://example.org/2"),
+			MaxLength:        15_000,
+			MaxGo:            1,
+			MaxHostErrors:    2,
+			HostParkDuration: time.Minute,
+			Fetcher:          Fetcher{RoundTripper: roundTripper},
+			Logger:           slog.New(sloghandlers.NewNullHandler()),
+		}))
+	}
+
+	// Parked after two errors
+	crawl()
+	assert.Equal(t, 2, roundTripper.count)
+	page, _, err := db.GetValue(HostKey("https", "example.org"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, page.Host.Errors)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), page.Host.ParkedUntil, time.Second*5)
+
+	// Still parked after a restart
+	crawl()
+	assert.Equal(t, 2, roundTripper.count)
+}
+
+func TestHostStateRetryAfter(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	roundTripper := &failRoundTripper{
+		status:     http.StatusTooManyRequests,
+		retryAfter: "1",
+		fails:      1,
+	}
+	assert.NoError(t, Crawl(context.Background(), Config{
+		DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+			return nil, db, nil
+		},
+		Input:     common.ParseURLs("https://example.org/", "https://example.org/1"),
+		MaxLength: 15_000,
+		MaxGo:     1,
+		Fetcher:   Fetcher{RoundTripper: roundTripper},
+		Logger:    slog.New(sloghandlers.NewNullHandler()),
+	}))
+
+	assert.Len(t, roundTripper.dates, 2)
+	assert.GreaterOrEqual(t, roundTripper.dates[1].Sub(roundTripper.dates[0]), time.Second)
+	assert.Equal(t, 1, db.CountHTML())
+	page, _, err := db.GetValue(HostKey("https", "example.org"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, page.Host.Errors)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, time.October, 21, 7, 28, 0, 0, time.UTC)
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, time.Hour, parseRetryAfter("Wed, 21 Oct 2015 08:28:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 21 Oct 2015 06:28:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-5", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("yolo", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
+
+// Respond the status for the first fails request of pages (all if fails
+// is zero), then respond an empty HTML page. Other files are not found.
+type failRoundTripper struct {
+	status     int
+	retryAfter string
+	fails      int
+
+	mutex sync.Mutex
+	// Number of request of pages.
+	count int
+	// The date of the request of pages.
+	dates []time.Time
+}
+
+func (r *failRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	response := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    request,
+	}
+	switch request.URL.Path {
+	case robotsPath, faviconPath, sitemapPath:
+		return response, nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.count++
+	r.dates = append(r.dates, time.Now())
+
+	if r.fails == 0 || r.count <= r.fails {
+		response.StatusCode = r.status
+		if r.retryAfter != "" {
+			response.Header.Set("Retry-After", r.retryAfter)
+		}
+	} else {
+		response.StatusCode = http.StatusOK
+		response.Body = io.NopCloser(bytes.NewReader([]byte(`<!DOCTYPE html><p>Hello</p>`)))
+	}
+
+	return response, nil
+}
@@ -3,6 +3,7 @@ package htmlnode
 import (
 	"golang.org/x/net/html/atom"
 	"net/url"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -12,12 +13,30 @@ type Meta struct {
 	Title       string
 	Description string
 
+	// From <meta name=robots>
 	NoFollow bool
 	NoIndex  bool
 
 	OpenGraph OpenGraph
 
 	LinkedData [][]byte
+
+	// RSS and Atom feeds (<link rel=alternate>), the URLs can be relative.
+	Feeds []url.URL
+
+	// The first icon (<link rel=icon>), the URL can be relative.
+	Icon url.URL
+
+	// The canonical URL (<link rel=canonical>), the URL can be relative.
+	Canonical url.URL
+
+	// The base URL of the relative links (<base href>), can be relative.
+	Base url.URL
+
+	// The target of <meta http-equiv=refresh>, the URL can be relative, and
+	// the delay in seconds before the refresh.
+	Refresh      url.URL
+	RefreshDelay int
 }
 
 // Fill .Meta field.
@@ -37,26 +56,56 @@ func (root *Root) fillMeta() {
 					root.Meta.LinkedData = append(root.Meta.LinkedData, []byte(node.Text))
 				}
 			}
+		case atom.Base:
+			if href := node.Attributes["href"]; href != "" {
+				// The first base is used, see the icon.
+				if u, _ := url.Parse(href); u != nil {
+					root.Meta.Base = *u
+				}
+			}
+		case atom.Link:
+			href := node.Attributes["href"]
+			if href == "" {
+				return
+			}
+			rels := strings.Fields(strings.ToLower(node.Attributes["rel"]))
+			if hasToken(rels, "icon") {
+				// Visit() walk from the last node, so the first icon overwrite the others.
+				if u, _ := url.Parse(href); u != nil {
+					root.Meta.Icon = *u
+				}
+			}
+			if hasToken(rels, "canonical") {
+				if u, _ := url.Parse(href); u != nil {
+					root.Meta.Canonical = *u
+				}
+			}
+			if hasToken(rels, "alternate") {
+				switch strings.ToLower(node.Attributes["type"]) {
+				case "application/rss+xml", "application/atom+xml":
+					if u, _ := url.Parse(href); u != nil {
+						root.Meta.Feeds = append(root.Meta.Feeds, *u)
+					}
+				}
+			}
 		case atom.Meta:
 			content := node.Attributes["content"]
 			if content == "" {
 				return
+			} else if strings.EqualFold(node.Attributes["http-equiv"], "refresh") {
+				if delay, u := parseRefresh(content); u != nil {
+					root.Meta.Refresh = *u
+					root.Meta.RefreshDelay = delay
+				}
+				return
 			}
-			switch node.Attributes["name"] {
+			switch name := strings.ToLower(node.Attributes["name"]); name {
 			case "description":
 				root.Meta.Description = content
 			case "robots":
-				for _, value := range strings.FieldsFunc(content, isSpaceAndComa) {
-					switch value {
-					case "noindex":
-						root.Meta.NoIndex = true
-					case "nofollow":
-						root.Meta.NoFollow = true
-					case "none":
-						root.Meta.NoIndex = true
-						root.Meta.NoFollow = true
-					}
-				}
+				directives := ParseRobots(content)
+				root.Meta.NoIndex = root.Meta.NoIndex || directives.NoIndex
+				root.Meta.NoFollow = root.Meta.NoFollow || directives.NoFollow
 			default:
 				if p := node.Attributes["property"]; strings.HasPrefix(p, "og:") {
 					openGraph = append(openGraph, [2]string{p, content})
@@ -69,8 +118,90 @@ func (root *Root) fillMeta() {
 	root.Meta.OpenGraph = parseOpenGraph(openGraph)
 }
 
+// Get the robots directives for one crawler, from the <meta> with the
+// crawler name (like <meta name=googlebot>), case insensitive.
+func (root *Root) AgentRobots(name string) (directives RobotsDirectives) {
+	root.Head.Visit(func(node Node) {
+		if node.TagName == atom.Meta && strings.EqualFold(node.Attributes["name"], name) {
+			d := ParseRobots(node.Attributes["content"])
+			directives.NoIndex = directives.NoIndex || d.NoIndex
+			directives.NoFollow = directives.NoFollow || d.NoFollow
+		}
+	})
+	return
+}
+
+// Parse the content of <meta http-equiv=refresh>, like "0; url='/page'".
+// The URL is nil if there is no URL (the page is reloaded).
+func parseRefresh(content string) (delay int, u *url.URL) {
+	content = strings.TrimSpace(content)
+	i := 0
+	for i < len(content) && '0' <= content[i] && content[i] <= '9' {
+		i++
+	}
+	delay, err := strconv.Atoi(content[:i])
+	if err != nil {
+		return 0, nil
+	}
+
+	target := strings.TrimLeft(content[i:], "0123456789.")
+	target = strings.TrimLeftFunc(target, func(r rune) bool { return r == ';' || isSpaceAndComa(r) })
+	if len(target) > 3 && strings.EqualFold(target[:3], "url") {
+		if after := strings.TrimLeftFunc(target[3:], unicode.IsSpace); strings.HasPrefix(after, "=") {
+			target = strings.TrimLeftFunc(after[1:], unicode.IsSpace)
+		}
+	}
+	if target != "" && (target[0] == '"' || target[0] == '\'') {
+		quote := target[0]
+		target = target[1:]
+		if end := strings.IndexByte(target, quote); end >= 0 {
+			target = target[:end]
+		}
+	}
+
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return delay, nil
+	}
+	u, _ = url.Parse(target)
+	return delay, u
+}
+
+// The robots directives, from <meta name=robots> or X-Robots-Tag header.
+type RobotsDirectives struct {
+	NoIndex  bool
+	NoFollow bool
+}
+
+// Parse the robots directives from a comma separated list, like
+// "noindex, nofollow". Unknown directives are ignored.
+func ParseRobots(content string) (directives RobotsDirectives) {
+	for _, value := range strings.FieldsFunc(strings.ToLower(content), isSpaceAndComa) {
+		switch value {
+		case "noindex":
+			directives.NoIndex = true
+		case "nofollow":
+			directives.NoFollow = true
+		case "none":
+			directives.NoIndex = true
+			directives.NoFollow = true
+		}
+	}
+	return
+}
+
 func isSpaceAndComa(r rune) bool { return r == ',' || unicode.IsSpace(r) }
 
+// Return true if the token is in the list.
+func hasToken(list []string, token string) bool {
+	for _, item := range list {
+		if item == token {
+			return true
+		}
+	}
+	return false
+}
+
 type OpenGraph struct {
 	// Required properties:
 	Title string
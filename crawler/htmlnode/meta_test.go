@@ -35,6 +35,81 @@ func TestFillMeta(t *testing.T) {
 			},
 		},
 
+		Icon: url.URL{
+			Scheme: "https",
+			Host:   "www.vie-publique.fr",
+			Path:   "/themes/custom/ldf/images/favicon.ico",
+		},
+
+		Canonical: url.URL{
+			Scheme: "https",
+			Host:   "www.vie-publique.fr",
+			Path:   "/en-bref/286907-maprimerenov-avis-du-defenseur-des-droits-sur-la-dematerialisation",
+		},
+
 		LinkedData: [][]byte{[]byte(`{"@context":"https://schema.org","@graph":[{"@type":"Article","headline":"MaPrimeRénov : la dématérialisation de la demande dénoncée par la Défenseure des droits","about":["\u003Ca href=\u0022/relations-administration-usager\u0022 hreflang=\u0022fr\u0022\u003ERelations administration usager\u003C/a\u003E","\u003Ca href=\u0022/simplification-administrative\u0022 hreflang=\u0022fr\u0022\u003ESimplification administrative\u003C/a\u003E"],"description":"La Défenseure des droits a été saisie de près de 500 réclamations rapportant les difficultés rencontrées par les usagers souhaitant bénéficier de MaPrimeRénov lors de leur démarche en ligne. Elle émet des recommandations à l\u0026#039;Agence nationale de l’habitat (Anah) en charge du dispositif d\u0026#039;aide à la rénovation des logements.","image":{"@type":"ImageObject","representativeOfPage":"True","url":"https://www.vie-publique.fr/sites/default/files/styles/medium/public/en_bref/image_principale/renovation-thermique.jpg?itok=dL3LLnKW","width":"220","height":"138"},"datePublished":"2022-10-27T14:00:00+0200","dateModified":"2022-10-27T11:26:08+0200","isAccessibleForFree":"True","author":{"@type":"Organization","@id":"vie-publique.fr","name":"vie-publique.fr","url":"https://www.vie-publique.fr/","sameAs":["https://www.facebook.com/viepubliquefr/","http://twitter.com/LaDocFrancaise","https://www.youtube.com/channel/UCwYVByKhnWvujETeZYM87Ng?view_as=subscriber","https://www.instagram.com/ladocumentationfrancaise/"],"logo":{"@type":"ImageObject","width":"600","height":"140","url":"https://www.vie-publique.fr/sites/default/files/LOGO%20VP%20-%20Desktop_0.png"}},"publisher":{"@type":"Organization","@id":"vie-publique.fr","name":"vie-publique.fr","url":"https://www.vie-publique.fr/","sameAs":["https://www.facebook.com/viepubliquefr/","http://twitter.com/LaDocFrancaise","https://www.youtube.com/channel/UCwYVByKhnWvujETeZYM87Ng?view_as=subscriber","https://www.instagram.com/ladocumentationfrancaise/"],"logo":{"@type":"ImageObject","width":"600","height":"140","url":"https://www.vie-publique.fr/sites/default/files/LOGO%20VP%20-%20Desktop_0.png"}},"mainEntityOfPage":"https://www.vie-publique.fr/en-bref/286907-maprimerenov-avis-du-defenseur-des-droits-sur-la-dematerialisation"}]}`)},
 	}, root.Meta)
 }
+
+func TestFillMetaFeeds(t *testing.T) {
+	root, err := Parse([]byte(`<html><head>
+		<link rel="alternate" type="application/rss+xml" href="/rss.xml">
+		<link rel="Alternate" type="application/atom+xml" href="https://example.org/atom.xml">
+		<link rel="alternate" type="application/x-wiki" href="/edit">
+		<link rel="stylesheet" href="/style.css">
+	</head><body></body></html>`))
+	assert.NoError(t, err)
+	// Visit() walks the nodes from the last one.
+	assert.Equal(t, []url.URL{
+		url.URL{Scheme: "https", Host: "example.org", Path: "/atom.xml"},
+		url.URL{Path: "/rss.xml"},
+	}, root.Meta.Feeds)
+}
+
+func TestFillMetaRobots(t *testing.T) {
+	root, err := Parse([]byte(`<html><head>
+		<meta name="robots" content="NoFollow">
+		<meta name="GoogleBot" content="noindex">
+		<meta name="istysearch" content="none">
+		<meta name="viewport" content="width=device-width">
+		<meta name="keywords" content="none">
+	</head><body></body></html>`))
+	assert.NoError(t, err)
+	assert.False(t, root.Meta.NoIndex)
+	assert.True(t, root.Meta.NoFollow)
+	assert.Equal(t, RobotsDirectives{NoIndex: true}, root.AgentRobots("googlebot"))
+	assert.Equal(t, RobotsDirectives{NoIndex: true, NoFollow: true}, root.AgentRobots("IstySearch"))
+	assert.Equal(t, RobotsDirectives{}, root.AgentRobots("bingbot"))
+}
+
+func TestFillMetaRefresh(t *testing.T) {
+	root, err := Parse([]byte(`<html><head>
+		<base href="/dir/">
+		<base href="/other/">
+		<meta http-equiv="Refresh" content="5; URL='new.html'">
+	</head><body></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, url.URL{Path: "/dir/"}, root.Meta.Base)
+	assert.Equal(t, url.URL{Path: "new.html"}, root.Meta.Refresh)
+	assert.Equal(t, 5, root.Meta.RefreshDelay)
+}
+
+func TestParseRefresh(t *testing.T) {
+	test := func(expectedDelay int, expectedURL, content string) {
+		t.Helper()
+		delay, u := parseRefresh(content)
+		assert.Equal(t, expectedDelay, delay, content)
+		if expectedURL == "" {
+			assert.Nil(t, u, content)
+		} else if assert.NotNil(t, u, content) {
+			assert.Equal(t, expectedURL, u.String(), content)
+		}
+	}
+
+	test(0, "", "")
+	test(0, "", "yolo")
+	test(300, "", "300")
+	test(0, "https://example.org/", "0;url=https://example.org/")
+	test(0, "/page", "0.5, URL = \"/page\"")
+	test(1, "page.html", " 1 ; page.html ")
+}
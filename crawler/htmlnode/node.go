@@ -26,7 +26,7 @@ type Root struct {
 
 	Meta Meta
 	Head Node
-	Body Node
+	Body Node // or the <frameset> element
 }
 
 // One html node, it can contain text or children. In case of pure text node,
@@ -57,6 +57,10 @@ func Parse(data []byte) (*Root, error) {
 	htmlNode := searchNode(firstNode, atom.Html)
 	headNode := searchNode(htmlNode, atom.Head)
 	bodyNode := searchNode(htmlNode, atom.Body)
+	if bodyNode == nil {
+		// A frame page, the <frameset> is used as body.
+		bodyNode = searchNode(htmlNode, atom.Frameset)
+	}
 	if htmlNode == nil || headNode == nil || bodyNode == nil {
 		return nil, NeedStructureElements
 	}
@@ -84,13 +88,15 @@ func searchNode(n *html.Node, expected atom.Atom) *html.Node {
 			return n
 		} else if n.FirstChild != nil {
 			n = n.FirstChild
-		} else if n.NextSibling != nil {
-			n = n.NextSibling
-		} else if n.Parent != nil && n.Parent.NextSibling != nil {
-			n = n.Parent.NextSibling
-		} else {
-			return nil
+			continue
 		}
+		for n.NextSibling == nil {
+			if n.Parent == nil {
+				return nil
+			}
+			n = n.Parent
+		}
+		n = n.NextSibling
 	}
 }
 
@@ -210,6 +216,24 @@ func (node Node) Walk(f func(Node) bool) {
 	}
 }
 
+// Get the text of the node and its children in the document order, with
+// the alt of the images. The spaces are collapsed.
+func (node *Node) TextContent() string {
+	parts := make([]string, 0)
+	node.textContent(&parts)
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+func (node *Node) textContent(parts *[]string) {
+	if node.TagName == atom.Img {
+		*parts = append(*parts, node.Attributes["alt"])
+	}
+	*parts = append(*parts, node.Text)
+	for i := range node.Children {
+		node.Children[i].textContent(parts)
+	}
+}
+
 func (node *Node) PrintLines() []string {
 	lines := make([]string, 0)
 	node.printLines("", &lines)
@@ -79,3 +79,11 @@ func TestParse(t *testing.T) {
 	assert.Equal(t, expected.Head.PrintLines(), received.Head.PrintLines())
 	assert.Equal(t, expected, received)
 }
+
+func TestTextContent(t *testing.T) {
+	root, err := Parse([]byte(`<html><head></head><body><a href="/">
+		Read <b>the  news</b> <img src="logo.png" alt="of the day">!
+	</a></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Read the news of the day !", root.Body.TextContent())
+}
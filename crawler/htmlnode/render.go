@@ -0,0 +1,76 @@
+package htmlnode
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Render the root as a HTML document. The ignored elements (style and
+// scripts) are lost, and the attributes are sorted.
+func (root *Root) Render(w io.Writer) error {
+	htmlNode := &html.Node{
+		Type:     html.ElementNode,
+		Data:     "html",
+		DataAtom: atom.Html,
+		Attr:     renderAttributes(root.RootId, root.RootClasses, root.RootAttributes),
+	}
+	root.Head.appendTo(htmlNode)
+	root.Body.appendTo(htmlNode)
+
+	document := &html.Node{Type: html.DocumentNode}
+	document.AppendChild(&html.Node{Type: html.DoctypeNode, Data: "html"})
+	document.AppendChild(htmlNode)
+
+	return html.Render(w, document)
+}
+
+// Convert the node into html.Node and append it to parent. A node without
+// TagName (a text or an unknown element) append its text and its children.
+func (node *Node) appendTo(parent *html.Node) {
+	if node.TagName == 0 {
+		if node.Text != "" {
+			parent.AppendChild(&html.Node{Type: html.TextNode, Data: node.Text})
+		}
+		for i := range node.Children {
+			node.Children[i].appendTo(parent)
+		}
+		return
+	}
+
+	element := &html.Node{
+		Type:      html.ElementNode,
+		Namespace: node.Namespace,
+		Data:      node.TagName.String(),
+		DataAtom:  node.TagName,
+		Attr:      renderAttributes(node.Id, node.Classes, node.Attributes),
+	}
+	if node.Text != "" {
+		element.AppendChild(&html.Node{Type: html.TextNode, Data: node.Text})
+	}
+	for i := range node.Children {
+		node.Children[i].appendTo(element)
+	}
+	parent.AppendChild(element)
+}
+
+func renderAttributes(id string, classes []string, attributes map[string]string) []html.Attribute {
+	attr := make([]html.Attribute, 0, len(attributes)+2)
+	if id != "" {
+		attr = append(attr, html.Attribute{Key: "id", Val: id})
+	}
+	if len(classes) > 0 {
+		attr = append(attr, html.Attribute{Key: "class", Val: strings.Join(classes, " ")})
+	}
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attr = append(attr, html.Attribute{Key: key, Val: attributes[key]})
+	}
+	return attr
+}
@@ -0,0 +1,30 @@
+package htmlnode
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	for _, src := range [][]byte{
+		exampleSimpleHtml,
+		[]byte(`<html><head></head><frameset><frame src="/a"></frameset></html>`),
+		[]byte(`<title>A &amp; B</title><p>a<br>b <my-element x="&quot;">c</my-element><svg><circle r="1"/></svg></p>`),
+	} {
+		root, err := Parse(src)
+		assert.NoError(t, err)
+
+		buff := bytes.Buffer{}
+		assert.NoError(t, root.Render(&buff))
+		rendered, err := Parse(buff.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, root.Meta, rendered.Meta)
+		assert.Equal(t, root.Body.TextContent(), rendered.Body.TextContent())
+
+		// Stable after the first render
+		buff2 := bytes.Buffer{}
+		assert.NoError(t, rendered.Render(&buff2))
+		assert.Equal(t, buff.String(), buff2.String())
+	}
+}
@@ -12,9 +12,6 @@ type logRoundTripper struct {
 }
 
 func newlogRoundTripper(roundTripper http.RoundTripper, logger *slog.Logger) http.RoundTripper {
-	if roundTripper == nil {
-		roundTripper = http.DefaultTransport
-	}
 	return &logRoundTripper{
 		logger:       logger,
 		roundTripper: roundTripper,
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	metricFetch = metrics.Default.NewCounter("isty_crawler_fetch_total",
+		"The number of fetch by HTTP status (0 without response) and outcome.",
+		"status", "outcome")
+	metricFetchDuration = metrics.Default.NewHistogram("isty_crawler_fetch_duration_seconds",
+		"The duration of the fetch, with the body read.",
+		0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30)
+	metricFetchBody = metrics.Default.NewHistogram("isty_crawler_fetch_body_bytes",
+		"The size of the fetched body.",
+		1e3, 1e4, 1e5, 1e6, 1e7)
+)
+
+// Measure the fetch result and duration.
+func observeFetch(result *fetchResult, duration time.Duration) {
+	outcome := "ok"
+	switch {
+	case result.err:
+		outcome = crawldatabase.TypeName(result.errType)
+	case result.notModified:
+		outcome = "notModified"
+	case result.redirect != nil:
+		outcome = "redirect"
+	}
+	metricFetch.Inc(strconv.Itoa(result.status), outcome)
+	metricFetchDuration.Observe(duration.Seconds())
+	if result.body != nil {
+		metricFetchBody.Observe(float64(result.body.Len()))
+	}
+}
+
+// Register the metrics of the crawl: the frontier, the workers and the
+// database. It replaces the metrics of the previous crawl.
+func (ctx *fetchContext) registerMetrics() {
+	metrics.Default.NewGaugeFunc("isty_crawler_frontier_urls",
+		"The number of URLs in the frontier.", nil,
+		func(add func(float64, ...string)) {
+			ctx.hostsMutex.Lock()
+			defer ctx.hostsMutex.Unlock()
+			n := 0
+			for _, h := range ctx.hosts {
+				n += len(h.queue)
+			}
+			add(float64(n))
+		})
+	metrics.Default.NewGaugeFunc("isty_crawler_frontier_hosts",
+		"The number of hosts in the frontier.", nil,
+		func(add func(float64, ...string)) {
+			ctx.hostsMutex.Lock()
+			defer ctx.hostsMutex.Unlock()
+			add(float64(len(ctx.hosts)))
+		})
+	metrics.Default.NewGaugeFunc("isty_crawler_goroutines",
+		"The number of crawl goroutines.", nil,
+		func(add func(float64, ...string)) {
+			ctx.hostsMutex.Lock()
+			defer ctx.hostsMutex.Unlock()
+			add(float64(ctx.lenGo))
+		})
+
+	metrics.Default.NewGaugeFunc("isty_crawler_db_entries",
+		"The number of entries in the database by type.", []string{"type"},
+		func(add func(float64, ...string)) {
+			stats := ctx.db.Statistics()
+			for t, count := range stats.Count {
+				if count > 0 {
+					add(float64(count), crawldatabase.TypeName(byte(t)))
+				}
+			}
+		})
+	metrics.Default.NewGaugeFunc("isty_crawler_db_bytes",
+		"The compressed size of the database files by type.", []string{"type"},
+		func(add func(float64, ...string)) {
+			stats := ctx.db.Statistics()
+			for t, size := range stats.FileSize {
+				if size > 0 {
+					add(float64(size), crawldatabase.TypeName(byte(t)))
+				}
+			}
+		})
+}
@@ -1,91 +1,313 @@
 package crawler
 
 import (
+	"github.com/HuguesGuilleus/isty-search/crawler/feed"
 	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
 	"github.com/HuguesGuilleus/isty-search/crawler/robotstxt"
+	"github.com/HuguesGuilleus/isty-search/crawler/sitemap"
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"golang.org/x/net/html/atom"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type Page struct {
 	URL url.URL
 
 	// Content, on of the following filed.
-	Html   *htmlnode.Root
-	Robots *robotstxt.File
+	Html    *htmlnode.Root
+	Robots  *robotstxt.File
+	Sitemap *sitemap.File
+	Feed    *feed.Feed
+	Favicon *Favicon
+	Host    *HostState
+
+	// The detected charset of the HTML page, before transcoding to UTF-8.
+	Charset string
+
+	// Informations to revisit the page, only for HTML page.
+	Revisit Revisit
+
+	// The SimHash of the visible text, only for HTML page. Zero if the text
+	// is too short.
+	SimHash uint64
+	// The source of the nofollow directive (like "x-robots-tag"), the links
+	// of the page are not crawled. Empty if the links are followed.
+	NoFollow string
+
+	// The fetch history of the robots.txt, only for robots.txt.
+	RobotsFetch *RobotsFetch
+
+	// The HTTP response of the content.
+	Response Response
 }
 
-// Get all urls of the page
-func (page *Page) GetURLs() map[keys.Key]*url.URL {
+// The metadata of the HTTP response of a page.
+type Response struct {
+	// The status code, zero if the page was not fetched (like the default
+	// robots.txt after a network error).
+	Status int
+	// The URL of the response, after the redirections followed for the
+	// robots.txt and the favicon.
+	URL url.URL
+
+	// Some headers of the response.
+	ContentType     string
+	LastModified    string
+	ETag            string
+	ContentLanguage string
+
+	// The duration of the request, with the body reading.
+	Duration time.Duration
+	// The size of the read body, before decompression. If Truncated, the
+	// body was bigger than the max length, only the begin is read.
+	BodySize  int
+	Truncated bool
+}
+
+// One link of a page.
+type Link struct {
+	URL *url.URL
+	// The source element: atom.A, atom.Area, atom.Iframe, atom.Frame,
+	// atom.Link (rel alternate, next or prev) or atom.Meta (a delayed
+	// refresh).
+	Element atom.Atom
+	// The anchor text of <a> (with the alt of the images), the alt of
+	// <area> or the title of the others elements.
+	Text string
+	// The link has rel=nofollow, ugc or sponsored.
+	NoFollow bool
+}
+
+// Get all links of the page, resolved with the <base href>.
+func (page *Page) GetLinks() []Link {
 	if page.Html == nil {
 		return nil
 	}
 
-	urls := make(map[keys.Key]*url.URL)
-	page.Html.Body.Visit(func(node htmlnode.Node) {
-		if node.TagName == atom.A {
-			if href := node.Attributes["href"]; href != "" {
-				u, _ := page.URL.Parse(href)
-				if u == nil || (u.Scheme != "https" && u.Scheme != "http") {
-					return
+	base := baseURL(&page.URL, &page.Html.Meta)
+	links := make([]Link, 0)
+	add := func(href string, node htmlnode.Node) {
+		if href == "" {
+			return
+		}
+		ref, _ := url.Parse(href)
+		if ref == nil {
+			return
+		}
+		u := base.ResolveReference(ref)
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return
+		}
+		link := Link{
+			URL:      u,
+			Element:  node.TagName,
+			Text:     node.Attributes["title"],
+			NoFollow: noFollowLink(node.Attributes["rel"]),
+		}
+		switch node.TagName {
+		case atom.A:
+			link.Text = node.TextContent()
+		case atom.Area:
+			link.Text = node.Attributes["alt"]
+		}
+		links = append(links, link)
+	}
+
+	visit := func(node htmlnode.Node) {
+		switch node.TagName {
+		case atom.A, atom.Area:
+			add(node.Attributes["href"], node)
+		case atom.Iframe, atom.Frame:
+			add(node.Attributes["src"], node)
+		case atom.Link:
+			for _, value := range strings.Fields(strings.ToLower(node.Attributes["rel"])) {
+				if value == "alternate" || value == "next" || value == "prev" {
+					add(node.Attributes["href"], node)
+					break
 				}
-				getParentURL(urls, u)
 			}
 		}
-	})
+	}
+	page.Html.Head.Visit(visit)
+	page.Html.Body.Visit(visit)
+
+	if meta := &page.Html.Meta; meta.Refresh != (url.URL{}) && meta.RefreshDelay > 0 {
+		add(meta.Refresh.String(), htmlnode.Node{TagName: atom.Meta})
+	}
 
+	return links
+}
+
+// Get all urls of the page and their parents, see GetLinks. The links with
+// rel=nofollow, ugc or sponsored are ignored.
+func (page *Page) GetURLs() map[keys.Key]*url.URL {
+	urls, _ := page.getURLs(Speculative{})
 	return urls
 }
 
-// Get all parent of the srouce url (no query, path parent, and path host.)
-func getParentURL(urls map[keys.Key]*url.URL, src *url.URL) {
+// Get all urls of the page and their speculative parents, with the
+// speculative rules.
+func (page *Page) getURLs(speculative Speculative) (map[keys.Key]*url.URL, map[keys.Key]string) {
+	if page.Html == nil {
+		return nil, nil
+	}
+
+	urls := make(map[keys.Key]*url.URL)
+	rules := make(map[keys.Key]string)
+	for _, link := range page.GetLinks() {
+		if !link.NoFollow {
+			getParentURL(urls, rules, link.URL, speculative)
+		}
+	}
+
+	return urls, rules
+}
+
+// Get the base URL of the relative links, from <base href>.
+func baseURL(u *url.URL, meta *htmlnode.Meta) *url.URL {
+	if meta.Base == (url.URL{}) {
+		return u
+	}
+	base := u.ResolveReference(&meta.Base)
+	if base.Scheme != "https" && base.Scheme != "http" {
+		return u
+	}
+	return base
+}
+
+// Get the target of a <meta http-equiv=refresh> without delay, used as a
+// redirection. Return nil if there is no redirection.
+func getRefresh(u *url.URL, meta *htmlnode.Meta) *url.URL {
+	if meta.Refresh == (url.URL{}) || meta.RefreshDelay > 0 {
+		return nil
+	}
+	target := absoluteURL(baseURL(u, meta), &meta.Refresh)
+	if target == nil || keys.NewURL(target) == keys.NewURL(u) {
+		return nil
+	}
+	return target
+}
+
+// The link has a rel nofollow, ugc (user generated content) or sponsored.
+func noFollowLink(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		switch value {
+		case "nofollow", "ugc", "sponsored":
+			return true
+		}
+	}
+	return false
+}
+
+// Get all RSS and Atom feeds URL of the page.
+func (page *Page) GetFeedURLs() map[keys.Key]*url.URL {
+	if page.Html == nil {
+		return nil
+	}
+
+	urls := make(map[keys.Key]*url.URL, len(page.Html.Meta.Feeds))
+	for i := range page.Html.Meta.Feeds {
+		if u := absoluteURL(baseURL(&page.URL, &page.Html.Meta), &page.Html.Meta.Feeds[i]); u != nil {
+			urls[keys.NewURL(u)] = u
+		}
+	}
+
+	return urls
+}
+
+// The speculative rules, see getParentURL.
+const (
+	speculativeQuery      = "no-query"
+	speculativeParentPath = "parent-path"
+	speculativePort       = "no-port"
+	speculativeParentHost = "parent-host"
+	speculativeWWW        = "www"
+)
+
+// The speculative URLs added from each link, see getParentURL. The zero
+// value enable all rules without limit.
+type Speculative struct {
+	// Disable the rules: remove the query, remove the port and add "www."
+	// to the host.
+	DisableQuery, DisablePort, DisableWWW bool
+	// The maximum number of parent paths and of parent hosts.
+	// Zero for no limit, negative to disable the rule.
+	MaxParentPath, MaxParentHost int
+}
+
+// Add the source url and its speculative parents (no query, path parent,
+// no port, parent host and www). The rule of each speculative URL is saved
+// in rules, a real link is removed from rules.
+func getParentURL(urls map[keys.Key]*url.URL, rules map[keys.Key]string, src *url.URL, speculative Speculative) {
 	// Source
 	cleanURL(src)
 	src.Host = strings.TrimSuffix(src.Host, ".")
 	if key := keys.NewURL(src); urls[key] != nil {
+		delete(rules, key)
 		return
 	} else {
 		urls[key] = src
+		delete(rules, key)
+	}
+
+	add := func(u *url.URL, rule string) bool {
+		key := keys.NewURL(u)
+		if urls[key] != nil {
+			return false
+		}
+		urls[key] = u
+		rules[key] = rule
+		return true
 	}
 
 	// No query
-	if src.RawQuery != "" {
+	if src.RawQuery != "" && !speculative.DisableQuery {
 		u := cloneURL(src)
 		u.RawQuery = ""
-		urls[keys.NewURL(u)] = u
+		add(u, speculativeQuery)
 	}
 
 	// Parent root
 	u := cloneURL(src)
 	u.RawQuery = ""
-	for i := len(u.Path) - 1; i >= 0; i-- {
+	for i, n := len(u.Path)-1, 0; i >= 0 && withinLimit(n, speculative.MaxParentPath); i-- {
 		if u.Path[i] == '/' {
 			u = cloneURL(u)
 			u.Path = u.Path[:i+1]
-			urls[keys.NewURL(u)] = u
+			if add(u, speculativeParentPath) {
+				n++
+			}
 		}
 	}
 	u = cloneURL(u)
 	u.Path = "/"
 
 	// Port
-	if newHost, _, cutted := strings.Cut(u.Host, ":"); cutted {
+	if newHost, _, cutted := strings.Cut(u.Host, ":"); cutted && !speculative.DisablePort {
 		u.Host = newHost
-		urls[keys.NewURL(u)] = u
+		add(u, speculativePort)
 		u = cloneURL(u)
 	}
 
 	// Parent host
 	count := strings.Count(u.Host, ".") - 1
-	for i := 0; i < count; i++ {
+	for i := 0; i < count && withinLimit(i, speculative.MaxParentHost); i++ {
 		_, u.Host, _ = strings.Cut(u.Host, ".")
-		urls[keys.NewURL(u)] = u
+		add(u, speculativeParentHost)
 		u = cloneURL(u)
 	}
-	u.Host = "www." + u.Host
-	urls[keys.NewURL(u)] = u
+	if !speculative.DisableWWW {
+		u.Host = "www." + u.Host
+		add(u, speculativeWWW)
+	}
+}
+
+// Return true if n is in the limit. The limit zero is no limit, negative
+// limit is disabled.
+func withinLimit(n, limit int) bool {
+	return limit == 0 || n < limit
 }
 
 func cloneURL(src *url.URL) *url.URL {
@@ -4,7 +4,10 @@ import (
 	_ "embed"
 	"github.com/HuguesGuilleus/isty-search/common"
 	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/keys"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html/atom"
+	"net/url"
 	"sort"
 	"testing"
 )
@@ -41,3 +44,104 @@ func TestGetURL(t *testing.T) {
 		"https://yolo.net/super/",
 	}, urls)
 }
+
+func TestGetLinks(t *testing.T) {
+	root, err := htmlnode.Parse([]byte(`<html><head>
+		<base href="/base/">
+		<link rel="alternate" hreflang="fr" href="fr.html">
+		<link rel="next" href="page-2.html">
+		<link rel="stylesheet" href="style.css">
+		<meta http-equiv="refresh" content="30; url=/later">
+	</head><body>
+		<a href="a.html">Page <b>A</b></a>
+		<a href="ugc.html" rel="ugc">UGC</a>
+		<map><area href="/area" alt="Area"></map>
+		<iframe src="https://example.org/frame" title="Frame"></iframe>
+	</body></html>`))
+	assert.NoError(t, err)
+
+	page := Page{URL: *common.ParseURL("https://example.com/dir/index.html"), Html: root}
+	links := make([]string, 0)
+	for _, link := range page.GetLinks() {
+		s := link.Element.String() + " " + link.URL.String() + " '" + link.Text + "'"
+		if link.NoFollow {
+			s += " nofollow"
+		}
+		links = append(links, s)
+	}
+	sort.Strings(links)
+	assert.Equal(t, []string{
+		"a https://example.com/base/a.html 'Page A'",
+		"a https://example.com/base/ugc.html 'UGC' nofollow",
+		"area https://example.com/area 'Area'",
+		"iframe https://example.org/frame 'Frame'",
+		"link https://example.com/base/fr.html ''",
+		"link https://example.com/base/page-2.html ''",
+		"meta https://example.com/later ''",
+	}, links)
+}
+
+func TestGetLinksFrameset(t *testing.T) {
+	root, err := htmlnode.Parse([]byte(`<html><head></head><frameset>
+		<frame src="menu.html">
+		<frame src="content.html">
+	</frameset></html>`))
+	assert.NoError(t, err)
+
+	page := Page{URL: *common.ParseURL("https://example.com/"), Html: root}
+	assert.Equal(t, []Link{
+		{URL: common.ParseURL("https://example.com/content.html"), Element: atom.Frame},
+		{URL: common.ParseURL("https://example.com/menu.html"), Element: atom.Frame},
+	}, page.GetLinks())
+}
+
+func TestGetRefresh(t *testing.T) {
+	u := common.ParseURL("https://example.com/dir/")
+
+	assert.Nil(t, getRefresh(u, &htmlnode.Meta{}))
+	assert.Nil(t, getRefresh(u, &htmlnode.Meta{Refresh: url.URL{Path: "other"}, RefreshDelay: 5}))
+	assert.Nil(t, getRefresh(u, &htmlnode.Meta{Refresh: url.URL{Path: "/dir/"}}))
+	assert.Equal(t, common.ParseURL("https://example.com/base/other"),
+		getRefresh(u, &htmlnode.Meta{Refresh: url.URL{Path: "other"}, Base: url.URL{Path: "/base/"}}))
+}
+
+func TestGetParentURL(t *testing.T) {
+	test := func(speculative Speculative, expected map[string]string) {
+		t.Helper()
+		urls := make(map[keys.Key]*url.URL)
+		rules := make(map[keys.Key]string)
+		getParentURL(urls, rules, common.ParseURL("https://a.b.example.org:8000/dir/subdir/page?b=2&a=1"), speculative)
+		getParentURL(urls, rules, common.ParseURL("https://a.b.example.org:8000/dir/"), speculative)
+
+		received := make(map[string]string, len(urls))
+		for key, u := range urls {
+			received[u.String()] = rules[key]
+		}
+		assert.Equal(t, expected, received)
+	}
+
+	test(Speculative{}, map[string]string{
+		"https://a.b.example.org:8000/dir/subdir/page?a=1&b=2": "",
+		"https://a.b.example.org:8000/dir/subdir/page":         speculativeQuery,
+		"https://a.b.example.org:8000/dir/subdir/":             speculativeParentPath,
+		"https://a.b.example.org:8000/dir/":                    "",
+		"https://a.b.example.org:8000/":                        speculativeParentPath,
+		"https://a.b.example.org/":                             speculativePort,
+		"https://b.example.org/":                               speculativeParentHost,
+		"https://example.org/":                                 speculativeParentHost,
+		"https://www.example.org/":                             speculativeWWW,
+	})
+
+	test(Speculative{
+		DisableQuery:  true,
+		DisablePort:   true,
+		DisableWWW:    true,
+		MaxParentPath: 1,
+		MaxParentHost: -1,
+	}, map[string]string{
+		"https://a.b.example.org:8000/dir/subdir/page?a=1&b=2": "",
+		"https://a.b.example.org:8000/dir/subdir/":             speculativeParentPath,
+		"https://a.b.example.org:8000/dir/":                    "",
+		"https://a.b.example.org:8000/":                        speculativeParentPath,
+	})
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Informations to revisit a page.
+type Revisit struct {
+	// The validators of the last response, used for conditional request.
+	ETag         string
+	LastModified string
+	// The hash of the last body.
+	Hash keys.Key
+	// Minimal duration between two fetch of the page.
+	Interval time.Duration
+}
+
+// Get the previous version of the page if it's a HTML page, else nil.
+func (ctx *fetchContext) getPrevious(key keys.Key) *Page {
+	if ctx.db.GetType(key) != crawldatabase.TypeFileHTML {
+		return nil
+	}
+
+	page, _, err := ctx.db.GetValue(key)
+	if err != nil {
+		return nil
+	}
+
+	return page
+}
+
+// Remove from urls the HTML pages that are not due: the revisit interval
+// is not over since the last fetch.
+func (ctx *fetchContext) removeNotDue(urls map[keys.Key]*url.URL) {
+	for key := range urls {
+		if ctx.db.GetType(key) != crawldatabase.TypeFileHTML {
+			continue
+		}
+		page, stored, err := ctx.db.GetValue(key)
+		if err == nil && time.Since(stored) < page.Revisit.Interval {
+			delete(urls, key)
+		}
+	}
+}
+
+// Create the header of the conditional request. Return nil if page is nil.
+func (page *Page) conditionalHeader() http.Header {
+	if page == nil {
+		return nil
+	}
+
+	header := make(http.Header)
+	if etag := page.Revisit.ETag; etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	if lastModified := page.Revisit.LastModified; lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+	}
+	return header
+}
+
+// The page is not modified, so save it again with a longer interval and
+// the new response. The body metadata of a 304 response (without body)
+// come from the previous response.
+func (ctx *fetchContext) refresh(key keys.Key, previous *Page, result *fetchResult) {
+	previous.Revisit = ctx.nextRevisit(previous, result, previous.Revisit.Hash)
+
+	response := result.pageResponse()
+	if result.notModified {
+		last := &previous.Response
+		response.BodySize = last.BodySize
+		response.Truncated = last.Truncated
+		if response.ContentType == "" {
+			response.ContentType = last.ContentType
+		}
+		if response.ContentLanguage == "" {
+			response.ContentLanguage = last.ContentLanguage
+		}
+		response.ETag = previous.Revisit.ETag
+		response.LastModified = previous.Revisit.LastModified
+	}
+	previous.Response = response
+
+	ctx.db.SetValue(key, previous, crawldatabase.TypeFileHTML)
+}
+
+// Compute the revisit informations after a fetch. The interval is doubled
+// if the page is unchanged, else divided by two. It stays in
+// [revisitAge, revisitMaxAge].
+func (ctx *fetchContext) nextRevisit(previous *Page, result *fetchResult, hash keys.Key) Revisit {
+	interval := ctx.revisitAge
+	if previous != nil {
+		if previous.Revisit.Hash == hash {
+			interval = previous.Revisit.Interval * 2
+		} else {
+			interval = previous.Revisit.Interval / 2
+		}
+	}
+
+	if ctx.revisitMaxAge > 0 && interval > ctx.revisitMaxAge {
+		interval = ctx.revisitMaxAge
+	}
+	if interval < ctx.revisitAge {
+		interval = ctx.revisitAge
+	}
+
+	revisit := Revisit{
+		ETag:         result.etag,
+		LastModified: result.lastModified,
+		Hash:         hash,
+		Interval:     interval,
+	}
+	if result.notModified {
+		if revisit.ETag == "" {
+			revisit.ETag = previous.Revisit.ETag
+		}
+		if revisit.LastModified == "" {
+			revisit.LastModified = previous.Revisit.LastModified
+		}
+	}
+
+	return revisit
+}
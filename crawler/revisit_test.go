@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestRevisit(t *testing.T) {
+	_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+	u := common.ParseURL("https://example.org/")
+	key := keys.NewURL(u)
+	roundTripper := &revisitRoundTripper{body: "<html><body>v1</body></html>", etag: `"v1"`}
+	crawl := func() {
+		assert.NoError(t, Crawl(context.Background(), Config{
+			DBopener: func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+				return nil, db, nil
+			},
+			Input:         []*url.URL{cloneURL(u)},
+			MaxLength:     15_000,
+			MaxGo:         1,
+			RevisitAge:    time.Nanosecond,
+			RevisitMaxAge: time.Hour,
+			Fetcher:       Fetcher{RoundTripper: roundTripper},
+			Logger:        slog.New(sloghandlers.NewNullHandler()),
+		}))
+	}
+	getRevisit := func() Revisit {
+		page, _, err := db.GetValue(key)
+		assert.NoError(t, err)
+		return page.Revisit
+	}
+
+	// First fetch
+	crawl()
+	assert.Equal(t, "", roundTripper.ifNoneMatch)
+	revisit := getRevisit()
+	assert.Equal(t, `"v1"`, revisit.ETag)
+	assert.Equal(t, time.Nanosecond, revisit.Interval)
+
+	// Not modified
+	crawl()
+	assert.Equal(t, `"v1"`, roundTripper.ifNoneMatch)
+	assert.Equal(t, 2*time.Nanosecond, getRevisit().Interval)
+	page, _, _ := db.GetValue(key)
+	assert.Equal(t, http.StatusNotModified, page.Response.Status)
+	assert.Equal(t, `"v1"`, page.Response.ETag)
+	assert.Equal(t, len(roundTripper.body), page.Response.BodySize)
+
+	// Same content without validator
+	roundTripper.etag = ""
+	crawl()
+	revisit = getRevisit()
+	assert.Equal(t, "", revisit.ETag)
+	assert.Equal(t, 4*time.Nanosecond, revisit.Interval)
+	page, _, _ = db.GetValue(key)
+	assert.Equal(t, http.StatusOK, page.Response.Status)
+	assert.Equal(t, "", page.Response.ETag)
+
+	// Modified
+	roundTripper.body = "<html><body>v2</body></html>"
+	roundTripper.etag = `"v2"`
+	crawl()
+	revisit = getRevisit()
+	assert.Equal(t, `"v2"`, revisit.ETag)
+	assert.Equal(t, 2*time.Nanosecond, revisit.Interval)
+
+	// Too young page are not planned, so the host is not crawled.
+	roundTripper.ifNoneMatch = "$none$"
+	roundTripper.count = 0
+	page, _, _ = db.GetValue(key)
+	page.Revisit.Interval = time.Hour
+	db.SetValue(key, page, crawldatabase.TypeFileHTML)
+	crawl()
+	assert.Equal(t, "$none$", roundTripper.ifNoneMatch)
+	assert.Equal(t, 0, roundTripper.count)
+}
+
+// Serve always the same page with an ETag, and respond 304 if the
+// request has the same ETag.
+type revisitRoundTripper struct {
+	body        string
+	etag        string
+	ifNoneMatch string
+	// The number of requests.
+	count int
+}
+
+func (r *revisitRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.count++
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader([]byte(r.body))),
+		Request:    request,
+	}
+	if request.URL.Path != "/" {
+		response.StatusCode = http.StatusNotFound
+		return response, nil
+	}
+
+	r.ifNoneMatch = request.Header.Get("If-None-Match")
+	if r.etag != "" {
+		response.Header.Set("ETag", r.etag)
+		if r.ifNoneMatch == r.etag {
+			response.StatusCode = http.StatusNotModified
+			response.Body = io.NopCloser(bytes.NewReader(nil))
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"strings"
+)
+
+// The source of the nofollow directive of a page, see Page.NoFollow.
+const (
+	noFollowMeta   = "meta"         // <meta name=robots>
+	noFollowAgent  = "meta-agent"   // <meta name=istysearch>
+	noFollowHeader = "x-robots-tag" // X-Robots-Tag header
+)
+
+// Parse the X-Robots-Tag header values. The directives are for all crawler,
+// or for one crawler with a prefix like "googlebot: noindex". The token is
+// the product token of our User-Agent.
+func parseRobotsTag(values []string, token string) (directives htmlnode.RobotsDirectives) {
+	for _, value := range values {
+		if agent, rest, ok := strings.Cut(value, ":"); ok && !strings.ContainsAny(agent, ",") {
+			agent = strings.TrimSpace(agent)
+			if !strings.EqualFold(agent, token) {
+				if htmlnode.ParseRobots(agent) != (htmlnode.RobotsDirectives{}) {
+					// Not an agent, like "noindex, unavailable_after: ..."
+					rest = value
+				} else {
+					continue
+				}
+			}
+			value = rest
+		}
+		d := htmlnode.ParseRobots(value)
+		directives.NoIndex = directives.NoIndex || d.NoIndex
+		directives.NoFollow = directives.NoFollow || d.NoFollow
+	}
+	return
+}
+
+// Get the error type if the page must not be indexed, the X-Robots-Tag
+// header, the <meta name=robots> and the <meta> for our crawler are
+// checked. The second value is the source of nofollow, or empty.
+func robotsDirectives(root *htmlnode.Root, robotsTag []string, token string) (noIndexType byte, noFollow string) {
+	header := parseRobotsTag(robotsTag, token)
+	agent := root.AgentRobots(token)
+
+	switch {
+	case header.NoIndex:
+		noIndexType = crawldatabase.TypeErrorNoIndexHeader
+	case root.Meta.NoIndex:
+		noIndexType = crawldatabase.TypeErrorNoIndex
+	case agent.NoIndex:
+		noIndexType = crawldatabase.TypeErrorNoIndexAgent
+	}
+
+	switch {
+	case header.NoFollow:
+		noFollow = noFollowHeader
+	case root.Meta.NoFollow:
+		noFollow = noFollowMeta
+	case agent.NoFollow:
+		noFollow = noFollowAgent
+	}
+
+	return
+}
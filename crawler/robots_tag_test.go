@@ -0,0 +1,40 @@
+package crawler
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRobotsTag(t *testing.T) {
+	assert.Equal(t, htmlnode.RobotsDirectives{}, parseRobotsTag(nil, "istysearch"))
+	assert.Equal(t, htmlnode.RobotsDirectives{NoIndex: true},
+		parseRobotsTag([]string{"NoIndex"}, "istysearch"))
+	assert.Equal(t, htmlnode.RobotsDirectives{NoIndex: true, NoFollow: true},
+		parseRobotsTag([]string{"noarchive", "none"}, "istysearch"))
+	assert.Equal(t, htmlnode.RobotsDirectives{NoFollow: true},
+		parseRobotsTag([]string{"googlebot: noindex", "IstySearch: nofollow"}, "istysearch"))
+	assert.Equal(t, htmlnode.RobotsDirectives{NoIndex: true},
+		parseRobotsTag([]string{"noindex, unavailable_after: 25 Jun 2010 15:00:00 PST"}, "istysearch"))
+}
+
+func TestRobotsDirectives(t *testing.T) {
+	root, err := htmlnode.Parse([]byte(`<html><head>
+		<meta name="robots" content="nofollow">
+		<meta name="istysearch" content="none">
+	</head><body></body></html>`))
+	assert.NoError(t, err)
+
+	noIndex, noFollow := robotsDirectives(&htmlnode.Root{}, nil, "istysearch")
+	assert.Equal(t, byte(0), noIndex)
+	assert.Equal(t, "", noFollow)
+
+	noIndex, noFollow = robotsDirectives(root, nil, "IstySearch")
+	assert.Equal(t, crawldatabase.TypeErrorNoIndexAgent, noIndex)
+	assert.Equal(t, noFollowMeta, noFollow)
+
+	noIndex, noFollow = robotsDirectives(root, []string{"none"}, "istysearch")
+	assert.Equal(t, crawldatabase.TypeErrorNoIndexHeader, noIndex)
+	assert.Equal(t, noFollowHeader, noFollow)
+}
@@ -26,50 +26,99 @@ type File struct {
 	// Link to all sitemap.
 	SiteMap []url.URL
 	// Allow and disallow rules. The rule are sorted by encoded pattern
-	// length decrement.
+	// length decrement, and allow before disallow for the same length.
 	Rules []Rule
 }
 
 var DefaultRobots = File{}
 
-// Parse the robots.txt file content to create a new File.
+// Parse the robots.txt file content to create a new File, with the rules
+// and the crawl delay of the groups that match the product token of
+// userAgent (see ProductToken), or the groups "*" if no group match. The
+// groups of the same user agent are merged.
 // Do not share memory with the input content.
-func Parse(content []byte) (file File) {
+func Parse(content []byte, userAgent string) (file File) {
 	rules := parseLines(content)
 
 	// Get global option sitemap
 	for _, rule := range rules {
+		if rule[0] == keySitemap {
+			u, _ := url.Parse(strings.Clone(rule[1]))
+			if u != nil && u.Scheme == "https" {
+				file.SiteMap = append(file.SiteMap, *u)
+			}
+		}
+	}
+
+	// Get crawl-delay, allow and disallow rules of the user-agent.
+	for _, rule := range filterLines(rules, ProductToken(userAgent)) {
 		switch rule[0] {
 		case keyCrawlDelay:
 			delay, err := strconv.Atoi(rule[1])
-			if err == nil && delay > 0 {
+			if err == nil && delay > file.CrawlDelay {
 				file.CrawlDelay = delay
 			}
-		case keySitemap:
-			u, _ := url.Parse(strings.Clone(rule[1]))
-			if u != nil && u.Scheme == "https" {
-				file.SiteMap = append(file.SiteMap, *u)
+		case keyAllow, keyDisallow:
+			m := parseMatcher(strings.Clone(rule[1]), rule[0] == keyAllow)
+			if m != nil {
+				file.Rules = append(file.Rules, *m)
 			}
 		}
 	}
 
-	// Get user-agent, allow and disallow rules.
-	for _, rule := range filterLines(rules) {
-		m := parseMatcher(strings.Clone(rule[1]), rule[0] == keyAllow)
-		if m != nil {
-			file.Rules = append(file.Rules, *m)
+	sort.SliceStable(file.Rules, func(i, j int) bool {
+		if li, lj := file.Rules[i].length(), file.Rules[j].length(); li != lj {
+			return li > lj
 		}
-	}
+		return file.Rules[i].Allow && !file.Rules[j].Allow
+	})
 
 	return
 }
 
-// Filter and sort only allow and disallow rule.
-func filterLines(rules [][2]string) (filtered [][2]string) {
+// Format the file as a robots.txt content, the rules are in the group "*".
+func (file *File) String() string {
+	buff := strings.Builder{}
+	buff.WriteString("User-agent: *\n")
+	if file.CrawlDelay > 0 {
+		buff.WriteString("Crawl-delay: " + strconv.Itoa(file.CrawlDelay) + "\n")
+	}
+	for i := range file.Rules {
+		buff.WriteString(file.Rules[i].String() + "\n")
+	}
+	if len(file.SiteMap) > 0 {
+		buff.WriteString("\n")
+	}
+	for i := range file.SiteMap {
+		buff.WriteString("Sitemap: " + file.SiteMap[i].String() + "\n")
+	}
+	return buff.String()
+}
+
+// Get the product token of the user agent, it's the first word before
+// the version. Example: "Isty/1.0 (+https://example.org/bot)" -> "Isty".
+func ProductToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return token
+}
+
+// Filter only the allow, disallow and crawl-delay rules of the groups that
+// match the product token. If no group match, use the groups "*". The rules
+// before the first user-agent are ignored.
+func filterLines(rules [][2]string, token string) (filtered [][2]string) {
+	accepted := "*"
+	for _, rule := range rules {
+		if rule[0] == keyUserAgent && token != "" && strings.EqualFold(ProductToken(rule[1]), token) {
+			accepted = token
+			break
+		}
+	}
+
 	filtered = make([][2]string, 0, len(rules))
 
 	middleOfGroup := true
-	isCurrentUserAgent := true
+	isCurrentUserAgent := false
 	for _, rule := range rules {
 		switch rule[0] {
 		case keyUserAgent:
@@ -77,10 +126,10 @@ func filterLines(rules [][2]string) (filtered [][2]string) {
 				middleOfGroup = false
 				isCurrentUserAgent = false
 			}
-			if rule[1] == "*" {
+			if accepted == "*" && rule[1] == "*" || accepted != "*" && strings.EqualFold(ProductToken(rule[1]), accepted) {
 				isCurrentUserAgent = true
 			}
-		case keyDisallow, keyAllow:
+		case keyDisallow, keyAllow, keyCrawlDelay:
 			middleOfGroup = true
 			if isCurrentUserAgent {
 				filtered = append(filtered, rule)
@@ -88,10 +137,6 @@ func filterLines(rules [][2]string) (filtered [][2]string) {
 		}
 	}
 
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i][1] > filtered[j][1]
-	})
-
 	return
 }
 
@@ -135,19 +180,52 @@ func lowerRuleName(ruleName string) string {
 	}
 }
 
-// Check if a url are allow or not by this robots.txt. Use only the path and
-// the query from u.
+// Check if a url are allow or not by this robots.txt. Use only the escaped
+// path and the query from u, with upper case percent-encoded octets like
+// the patterns. The rule with the longest pattern that matches
+// wins, allow wins if the patterns have the same length. The path
+// "/robots.txt" is always allowed.
 func (file *File) Allow(u *url.URL) bool {
-	testedURL := u.Path
-	if u.RawQuery != "" {
-		testedURL += u.RawQuery
+	_, allow := file.Match(u)
+	return allow
+}
+
+// Get the rule that matches u (nil if none) and if u is allowed, see
+// File.Allow.
+func (file *File) Match(u *url.URL) (*Rule, bool) {
+	testedURL := u.EscapedPath()
+	if testedURL == "" {
+		testedURL = "/"
+	} else if testedURL == "/robots.txt" {
+		return nil, true
 	}
+	if u.RawQuery != "" || u.ForceQuery {
+		testedURL += "?" + u.RawQuery
+	}
+	testedURL = upperPercent(testedURL)
 
-	for _, rule := range file.Rules {
-		if rule.match(testedURL) {
-			return rule.Allow
+	for i := range file.Rules {
+		if file.Rules[i].match(testedURL) {
+			return &file.Rules[i], file.Rules[i].Allow
 		}
 	}
 
-	return true
+	return nil, true
+}
+
+// Use upper case hexadecimal digits in the percent-encoded octets of s.
+func upperPercent(s string) string {
+	i := strings.IndexByte(s, '%')
+	if i < 0 {
+		return s
+	}
+	b := []byte(s)
+	for ; i+2 < len(b); i++ {
+		if b[i] == '%' && isHex(b[i+1]) && isHex(b[i+2]) {
+			b[i+1] = upperHex(b[i+1])
+			b[i+2] = upperHex(b[i+2])
+			i += 2
+		}
+	}
+	return string(b)
 }
@@ -18,18 +18,55 @@ func TestParse(t *testing.T) {
 		},
 		Rules: []Rule{
 			{true, "/squelettes/images/", []string{}, false},
-			{false, "/squelettes/", []string{}, false},
+			{true, "/local/cache-css/", []string{}, false},
 			{false, "/squelettes-dist/", []string{}, false},
-			{false, "/prive/", []string{}, false},
-			{false, "/plugins/", []string{}, false},
 			{true, "/local/cache-js/", []string{}, false},
-			{true, "/local/cache-css/", []string{}, false},
-			{true, "/local/", []string{}, false},
-			{false, "/lib/", []string{}, false},
 			{false, "/extensions/", []string{}, false},
+			{false, "/squelettes/", []string{}, false},
+			{false, "/plugins/", []string{}, false},
 			{false, "/ecrire/", []string{}, false},
+			{true, "/local/", []string{}, false},
+			{false, "/prive/", []string{}, false},
+			{false, "/lib/", []string{}, false},
 		},
-	}, Parse(robotstxttestdata.MondeDiplomatique))
+	}, Parse(robotstxttestdata.MondeDiplomatique, ""))
+}
+
+func TestParseUserAgent(t *testing.T) {
+	content := []byte(`
+User-agent: *
+Disallow: /all/
+
+User-agent: GoogleBot
+User-agent: isty
+Disallow: /isty/
+
+User-agent: Isty-News
+Disallow: /news/
+
+user-agent: ISTY/2.0
+Allow: /isty/public/
+`)
+
+	assert.Equal(t, []Rule{
+		{true, "/isty/public/", []string{}, false},
+		{false, "/isty/", []string{}, false},
+	}, Parse(content, "Isty/1.0 (+https://example.org/bot)").Rules)
+	assert.Equal(t, []Rule{
+		{false, "/news/", []string{}, false},
+	}, Parse(content, "isty-news").Rules)
+	assert.Equal(t, []Rule{
+		{false, "/all/", []string{}, false},
+	}, Parse(content, "Other/1.0").Rules)
+	assert.Equal(t, []Rule{
+		{false, "/all/", []string{}, false},
+	}, Parse(content, "").Rules)
+}
+
+func TestProductToken(t *testing.T) {
+	assert.Equal(t, "Isty", ProductToken("Isty/1.0 (+https://example.org/bot)"))
+	assert.Equal(t, "Isty", ProductToken(" Isty "))
+	assert.Equal(t, "", ProductToken(""))
 }
 
 func TestDevCutLines(t *testing.T) {
@@ -57,7 +94,7 @@ Disallow: /youth/dissemination/`+
 }
 
 func TestFileAllow(t *testing.T) {
-	file := Parse(robotstxttestdata.MondeDiplomatique)
+	file := Parse(robotstxttestdata.MondeDiplomatique, "")
 
 	allow := func(urlString string, expected bool) {
 		assert.Equal(t, expected, file.Allow(common.ParseURL(urlString)), urlString)
@@ -85,6 +122,147 @@ func TestDefaultRobots(t *testing.T) {
 
 func BenchmarkWikipedia(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		Parse(robotstxttestdata.Wikipedia)
+		Parse(robotstxttestdata.Wikipedia, "")
 	}
 }
+
+func TestFileString(t *testing.T) {
+	file := Parse(robotstxttestdata.MondeDiplomatique, "")
+	s := file.String()
+	assert.Contains(t, s, "User-agent: *\nCrawl-delay: 1\nAllow: /squelettes/images/\nAllow: /local/cache-css/\nDisallow: /squelettes-dist/\n")
+	assert.Contains(t, s, "\n\nSitemap: https://www.monde-diplomatique.fr/sitemap.xml\n")
+	assert.Equal(t, file, Parse([]byte(s), ""))
+}
+
+// Test cases from Google's robots.txt parser (robots_test.cc),
+// https://github.com/google/robotstxt
+func TestGoogleReference(t *testing.T) {
+	allowed := func(robots, userAgent, rawURL string) bool {
+		file := Parse([]byte(robots), userAgent)
+		return file.Allow(common.ParseURL(rawURL))
+	}
+
+	// User-agent line is case insensitive, only the product token is used.
+	robots := "User-Agent: FOO BAR\nAllow: /x/\nDisallow: /\n"
+	assert.True(t, allowed(robots, "Foo", "http://foo.bar/x/y"))
+	assert.False(t, allowed(robots, "Foo", "http://foo.bar/a/b"))
+	assert.True(t, allowed(robots, "Bar", "http://foo.bar/a/b"))
+	robots = "user-agent: foo\nallow: /x/\ndisallow: /\n"
+	assert.True(t, allowed(robots, "FOO", "http://foo.bar/x/y"))
+	assert.False(t, allowed(robots, "FOO", "http://foo.bar/a/b"))
+
+	// Global group is used only without specific group.
+	robots = "user-agent: *\nallow: /\nuser-agent: FooBot\ndisallow: /\n"
+	assert.True(t, allowed(robots, "BarBot", "http://foo.bar/x/y"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/x/y"))
+	robots = "user-agent: FooBot\nallow: /\nuser-agent: BarBot\ndisallow: /\nuser-agent: BazBot\ndisallow: /\n"
+	assert.True(t, allowed(robots, "QuxBot", "http://foo.bar/x/y"))
+
+	// The values are case sensitive.
+	assert.False(t, allowed("user-agent: FooBot\ndisallow: /x/\n", "FooBot", "http://foo.bar/x/y"))
+	assert.True(t, allowed("user-agent: FooBot\ndisallow: /X/\n", "FooBot", "http://foo.bar/x/y"))
+
+	// The longest match wins, allow wins equivalent patterns. The final *
+	// is counted.
+	robots = "user-agent: FooBot\nallow: /fish\ndisallow: /fish*\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/fish.html"))
+	robots = "user-agent: FooBot\ndisallow: /x/page.html\nallow: /x/page.html\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/page.html"))
+	robots = "user-agent: FooBot\nallow: /page\ndisallow: /*.html\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/page.html"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/page"))
+	robots = "user-agent: FooBot\nallow: /x/page.\ndisallow: /*.html\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/page.html"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/x/y.html"))
+	robots = "user-agent: FooBot\nallow: /\ndisallow: /\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/page.html"))
+	robots = "user-agent: FooBot\ndisallow: /x/\nallow: /x/page.html\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/page.html"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/x/other.html"))
+	robots = "User-agent: *\nDisallow: /x/\nUser-agent: FooBot\nDisallow: /y/\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/page"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/y/page"))
+
+	// Encoding: the query is matched, the non ASCII characters are encoded,
+	// the encoded pattern matches only the encoded URL.
+	robots = "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar?qux=taz&baz=http://foo.bar?tar&par\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar?qux=taz&baz=http://foo.bar?tar&par"))
+	robots = "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/ツ\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/%E3%83%84"))
+	robots = "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/%E3%83%84\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/%E3%83%84"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/%e3%83%84"))
+	robots = "User-agent: FooBot\nDisallow: /\nAllow: /foo/bar/%62%61%7A\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/baz"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/%62%61%7A"))
+
+	// Special characters.
+	robots = "User-agent: FooBot\nDisallow: /foo/bar/quz\nAllow: /foo/*/qux\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/quz"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/quz"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo//quz"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bax/quz"))
+	robots = "User-agent: FooBot\nDisallow: /foo/bar$\nAllow: /foo/bar/qux\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/qux"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/baz"))
+	robots = "User-agent: FooBot\n# Disallow: /\nDisallow: /foo/quz#qux\nAllow: /\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/foo/quz"))
+
+	// Documentation patterns.
+	patterns := func(pattern string, expected map[string]bool) {
+		t.Helper()
+		robots := "user-agent: FooBot\ndisallow: /\nallow: " + pattern + "\n"
+		for path, allow := range expected {
+			assert.Equal(t, allow, allowed(robots, "FooBot", "http://foo.bar"+path), pattern+" "+path)
+		}
+	}
+	patterns("/fish", map[string]bool{
+		"/fish": true, "/fish.html": true, "/fish/salmon.html": true,
+		"/fishheads": true, "/fishheads/yummy.html": true, "/fish.html?id=anything": true,
+		"/Fish.asp": false, "/catfish": false, "/?id=fish": false, "/bar": false,
+	})
+	patterns("/fish*", map[string]bool{
+		"/fish": true, "/fish.html": true, "/fishheads/yummy.html": true,
+		"/Fish.bar": false, "/catfish": false, "/?id=fish": false,
+	})
+	patterns("/fish/", map[string]bool{
+		"/fish/": true, "/fish/?id=anything": true, "/fish/salmon.htm": true,
+		"/fish": false, "/fish.html": false, "/Fish/Salmon.asp": false,
+	})
+	patterns("/*.php", map[string]bool{
+		"/filename.php": true, "/folder/filename.php": true,
+		"/folder/filename.php?parameters": true, "/folder/any.php.file.html": true,
+		"/filename.php/": true, "/index?f=filename.php/": true,
+		"/php/": false, "/index?php": false, "/windows.PHP": false,
+	})
+	patterns("/*.php$", map[string]bool{
+		"/filename.php": true, "/folder/filename.php": true,
+		"/filename.php?parameters": false, "/filename.php/": false,
+		"/filename.php5": false, "/php/": false, "/windows.PHP": false,
+	})
+	patterns("/fish*.php", map[string]bool{
+		"/fish.php": true, "/fishheads/catfish.php?parameters": true,
+		"/Fish.PHP": false,
+	})
+
+	// Empty path, robots.txt and query.
+	assert.False(t, allowed("user-agent: *\ndisallow: /\n", "FooBot", "http://foo.bar"))
+	assert.True(t, allowed("user-agent: *\ndisallow: /\n", "FooBot", "http://foo.bar/robots.txt"))
+	robots = "user-agent: *\ndisallow: /*?\n"
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/a?b=c"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/a"))
+
+	// The groups of the same user agent are merged, the rules before the
+	// first user-agent and the empty rules are ignored.
+	robots = "allow: /foo/bar/\n\nuser-agent: FooBot\ndisallow: /\nallow: /x/\nuser-agent: BarBot\ndisallow: /\nallow: /y/\n\n\nallow: /w/\nuser-agent: BazBot\n\nuser-agent: FooBot\nallow: /z/\ndisallow: /\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/x/b"))
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/z/d"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/y/c"))
+	assert.False(t, allowed(robots, "FooBot", "http://foo.bar/foo/bar/"))
+	assert.True(t, allowed(robots, "BarBot", "http://foo.bar/w/a"))
+	robots = "user-agent: FooBot\ndisallow:\nallow:\n"
+	assert.True(t, allowed(robots, "FooBot", "http://foo.bar/"))
+}
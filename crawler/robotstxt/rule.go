@@ -1,44 +1,38 @@
 package robotstxt
 
 import (
-	"net/url"
 	"strings"
 )
 
-// One allow or disallow parsed rule.
+// One allow or disallow parsed rule. The pattern parts are percent-encoded
+// like an escaped URL path, they are compared byte by byte.
 type Rule struct {
 	// Allow or Disallow
 	Allow bool
 	// Part before every *
 	First string
-	// Splited part between every *, without the first.
+	// Splited part between every *, without the first. A final * is an
+	// empty last part.
 	Middle []string
 	// End with '$', so must match exact
 	EndMatch bool
 }
 
-// Parsed the patern to create a new rule.
+// Parsed the patern to create a new rule, nil if the pattern is empty.
+// The pattern is normalized, see escapePattern().
 func parseMatcher(pattern string, allow bool) *Rule {
-	endMatch := false
-	if l := len(pattern) - 1; l > 0 {
-		switch pattern[l] {
-		case '$':
-			pattern = pattern[:l]
-			endMatch = true
-		case '*':
-			pattern = pattern[:l]
-		}
+	if pattern == "" {
+		return nil
 	}
 
-	splitedPattern := strings.Split(pattern, "*")
-	for i, a := range splitedPattern {
-		a, err := url.QueryUnescape(a)
-		if err != nil {
-			return nil
-		}
-		splitedPattern[i] = a
+	endMatch := false
+	if l := len(pattern) - 1; l > 0 && pattern[l] == '$' {
+		pattern = pattern[:l]
+		endMatch = true
 	}
 
+	splitedPattern := strings.Split(escapePattern(pattern), "*")
+
 	return &Rule{
 		Allow:    allow,
 		First:    splitedPattern[0],
@@ -47,13 +41,100 @@ func parseMatcher(pattern string, allow bool) *Rule {
 	}
 }
 
+// Normalize the pattern like Google robots.txt parser: percent-encode the
+// spaces, the control and the non-ASCII characters, and use upper case
+// hexadecimal digits in the percent-encoded octets.
+//
+// Example: "/SanJoséSellers" -> "/SanJos%C3%A9Sellers" and "%aa" -> "%AA".
+func escapePattern(pattern string) string {
+	const hex = "0123456789ABCDEF"
+	buff := strings.Builder{}
+	buff.Grow(len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '%' && i+2 < len(pattern) && isHex(pattern[i+1]) && isHex(pattern[i+2]):
+			buff.WriteByte('%')
+			buff.WriteByte(upperHex(pattern[i+1]))
+			buff.WriteByte(upperHex(pattern[i+2]))
+			i += 2
+		case c <= ' ', c >= 0x7F:
+			buff.WriteByte('%')
+			buff.WriteByte(hex[c>>4])
+			buff.WriteByte(hex[c&0xF])
+		default:
+			buff.WriteByte(c)
+		}
+	}
+	return buff.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func upperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// Format the rule as a robots.txt line, like "Disallow: /a*b$".
+func (rule *Rule) String() string {
+	buff := strings.Builder{}
+	if rule.Allow {
+		buff.WriteString("Allow: ")
+	} else {
+		buff.WriteString("Disallow: ")
+	}
+	buff.WriteString(rule.First)
+	for _, middle := range rule.Middle {
+		buff.WriteByte('*')
+		buff.WriteString(middle)
+	}
+	if rule.EndMatch {
+		buff.WriteByte('$')
+	} else if s := buff.String(); strings.HasSuffix(s, "$") {
+		// A literal '$', not the end.
+		buff.WriteByte('*')
+	}
+	return buff.String()
+}
+
+// The length of the pattern as written (once normalized), the most
+// specific rule has the longest pattern.
+func (rule *Rule) length() int {
+	l := len(rule.First)
+	for _, middle := range rule.Middle {
+		l += 1 + len(middle)
+	}
+	if rule.EndMatch {
+		l++
+	}
+	return l
+}
+
 func (rule *Rule) match(testedURL string) bool {
 	if !strings.HasPrefix(testedURL, rule.First) {
 		return false
 	}
 	testedURL = testedURL[len(rule.First):]
 
-	for _, middle := range rule.Middle {
+	middles := rule.Middle
+	if rule.EndMatch {
+		if len(middles) == 0 {
+			return testedURL == ""
+		}
+		// The last part must be at the end.
+		last := middles[len(middles)-1]
+		if !strings.HasSuffix(testedURL, last) {
+			return false
+		}
+		testedURL = testedURL[:len(testedURL)-len(last)]
+		middles = middles[:len(middles)-1]
+	}
+
+	for _, middle := range middles {
 		found := false
 		_, testedURL, found = strings.Cut(testedURL, middle)
 		if !found {
@@ -61,8 +142,5 @@ func (rule *Rule) match(testedURL string) bool {
 		}
 	}
 
-	if rule.EndMatch {
-		return testedURL == ""
-	}
 	return true
 }
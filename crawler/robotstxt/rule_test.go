@@ -11,11 +11,13 @@ func TestMatcher(t *testing.T) {
 		assert.Equal(t, rule, found, pattern)
 	}
 	tester("/", &Rule{true, "/", []string{}, false})
-	tester("/%64%69%72", &Rule{true, "/dir", []string{}, false})
+	tester("/%64%69%72", &Rule{true, "/%64%69%72", []string{}, false})
+	tester("/caf%c3%a9/é", &Rule{true, "/caf%C3%A9/%C3%A9", []string{}, false})
 	tester("*file.txt", &Rule{true, "", []string{"file.txt"}, false})
-	tester("/dir/*", &Rule{true, "/dir/", []string{}, false})
+	tester("/dir/*", &Rule{true, "/dir/", []string{""}, false})
 	tester("/dir*file.txt", &Rule{true, "/dir", []string{"file.txt"}, false})
 	tester("*file.txt$", &Rule{true, "", []string{"file.txt"}, true})
+	tester("", nil)
 }
 
 func TestAllower(t *testing.T) {
@@ -27,11 +29,42 @@ func TestAllower(t *testing.T) {
 	}
 
 	tester("/", "/dir/subdir/file.txt", true)
-	tester("/%64%69%72", "/dir/subdir/file.txt", true)
+	tester("/%64%69%72", "/%64%69%72/subdir/file.txt", true)
+	tester("/%64%69%72", "/dir/subdir/file.txt", false)
 	tester("*file.txt", "/dir/subdir/file.txt", true)
 	tester("/dir*file.txt", "/dir/subdir/file.txt", true)
 	tester("/$", "/", true)
+	tester("/*.php$", "/a.php.php", true)
+	tester("/a*a$", "/aa", true)
 
 	tester("/$", "/dir/subdir/file.txt.odt", false)
 	tester("*file.txt$", "/dir/subdir/file.txt.odt", false)
+	tester("/a*a$", "/a", false)
+	tester("/dir/*", "/dir/file.txt", true)
+	tester("/dir/*", "/file.txt", false)
+}
+
+func TestRuleLength(t *testing.T) {
+	assert.Equal(t, 5, parseMatcher("/fish", true).length())
+	assert.Equal(t, 6, parseMatcher("/fish*", true).length())
+	assert.Equal(t, 6, parseMatcher("/fish$", true).length())
+	assert.Equal(t, 7, parseMatcher("/*.php$", true).length())
+	assert.Equal(t, 10, parseMatcher("/caf%c3%a9", true).length())
+}
+
+func TestRuleString(t *testing.T) {
+	tester := func(pattern, expected string) {
+		rule := parseMatcher(pattern, false)
+		assert.Equal(t, expected, rule.String(), pattern)
+		assert.Equal(t, rule, parseMatcher(expected[len("Disallow: "):], false), pattern)
+	}
+	tester("/", "Disallow: /")
+	tester("/%64%69%72", "Disallow: /%64%69%72")
+	tester("/é", "Disallow: /%C3%A9")
+	tester("/a$*", "Disallow: /a$*")
+	tester("*file.txt$", "Disallow: *file.txt$")
+	tester("/dir/*", "Disallow: /dir/*")
+	tester("/a%2Ab%24c%20d%2B*e", "Disallow: /a%2Ab%24c%20d%2B*e")
+
+	assert.Equal(t, "Allow: /dir*file", parseMatcher("/dir*file", true).String())
 }
@@ -0,0 +1,313 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"golang.org/x/exp/slog"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The maximum number of URLs forwarded to a shard in one request.
+const shardBatch = 1000
+
+// The delay before a new try to forward URLs to an unavailable shard.
+const shardRetry = time.Second
+
+// The maximum length of a Unix socket path: sun_path is 108 bytes on Linux
+// and 104 on BSD and macOS, with the final NUL.
+const maxSocketPath = 103
+
+// Check that every shard socket path is short enough for a Unix socket.
+func CheckShardSockets(sockets []string) error {
+	for _, socket := range sockets {
+		if len(socket) > maxSocketPath {
+			return fmt.Errorf("The shard socket path %q is too long (%d bytes, maximum %d), use a shorter database path", socket, len(socket), maxSocketPath)
+		}
+	}
+	return nil
+}
+
+// Get the shard of the host, a stable hash of createKey(scheme, host).
+func ShardOf(scheme, host string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(createKey(scheme, host)))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// One URL forwarded to an other shard, with its origin.
+type shardURL struct {
+	URL         string `json:"url"`
+	Depth       int    `json:"depth"`
+	Speculative string `json:"speculative,omitempty"`
+	Source      string `json:"source,omitempty"`
+}
+
+// Forward the URLs to the shard of their host, with a HTTP request on
+// the Unix socket of the shard.
+type shardForwarder struct {
+	logger *slog.Logger
+	// The shard of this crawl.
+	shard   int
+	sockets []string
+	clients []*http.Client
+
+	mutex sync.Mutex
+	// URLs to send by shard, and the total of URLs not yet sent.
+	queues  [][]shardURL
+	pending int
+	// Wake the sender goroutine of each shard.
+	wake []chan struct{}
+
+	// Number of URLs sent to the other shards and received from them.
+	sent, received atomic.Int64
+}
+
+func newShardForwarder(logger *slog.Logger, shard int, sockets []string) *shardForwarder {
+	f := &shardForwarder{
+		logger:  logger,
+		shard:   shard,
+		sockets: sockets,
+		clients: make([]*http.Client, len(sockets)),
+		queues:  make([][]shardURL, len(sockets)),
+		wake:    make([]chan struct{}, len(sockets)),
+	}
+	for i, socket := range sockets {
+		f.clients[i] = unixClient(socket)
+		f.wake[i] = make(chan struct{}, 1)
+	}
+	return f
+}
+
+// Create a HTTP client that connect to the Unix socket.
+func unixClient(socket string) *http.Client {
+	dialer := net.Dialer{Timeout: time.Second * 10}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: time.Second * 30,
+	}
+}
+
+// Launch a goroutine by other shard to send the URLs, until the context
+// is done. The unsent URLs are lost, but they are saved as known URLs in
+// the database, so they are forwarded again at the next crawl.
+func (f *shardForwarder) start(ctx context.Context) {
+	if f == nil {
+		return
+	}
+	for shard := range f.sockets {
+		if shard != f.shard {
+			go f.run(ctx, shard)
+		}
+	}
+}
+
+// Remove from urls the URLs of the other shards, and queue them with their
+// origin (origins can be nil).
+func (f *shardForwarder) forward(urls map[keys.Key]*url.URL, origins map[keys.Key]crawldatabase.Origin) {
+	if f == nil {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for key, u := range urls {
+		shard := ShardOf(u.Scheme, u.Host, len(f.sockets))
+		if shard == f.shard {
+			continue
+		}
+		delete(urls, key)
+
+		item := shardURL{URL: u.String()}
+		if origin, ok := origins[key]; ok {
+			item.Depth = origin.Depth
+			item.Speculative = origin.Speculative
+			if origin.Source != nil {
+				item.Source = origin.Source.String()
+			}
+		}
+		f.queues[shard] = append(f.queues[shard], item)
+		f.pending++
+		select {
+		case f.wake[shard] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Send the queued URLs of the shard.
+func (f *shardForwarder) run(ctx context.Context, shard int) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake[shard]:
+		}
+
+		for batch := f.take(shard); len(batch) > 0; batch = f.take(shard) {
+			for f.send(ctx, shard, batch) != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(shardRetry):
+				}
+			}
+			f.mutex.Lock()
+			f.pending -= len(batch)
+			f.mutex.Unlock()
+			f.sent.Add(int64(len(batch)))
+		}
+	}
+}
+
+// Take at most shardBatch URLs from the queue of the shard.
+func (f *shardForwarder) take(shard int) []shardURL {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	queue := f.queues[shard]
+	n := len(queue)
+	if n > shardBatch {
+		n = shardBatch
+	}
+	batch := append([]shardURL(nil), queue[:n]...)
+	f.queues[shard] = queue[n:]
+	return batch
+}
+
+func (f *shardForwarder) send(ctx context.Context, shard int, batch []shardURL) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://shard/forward", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := f.clients[shard].Do(request)
+	if err != nil {
+		f.logger.Warn("crawl.shard.forward", "shard", shard, "err", err.Error())
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode != http.StatusNoContent {
+		f.logger.Warn("crawl.shard.forward", "shard", shard, "status", response.StatusCode)
+		return fmt.Errorf("Forward to the shard %d: status %d", shard, response.StatusCode)
+	}
+	return nil
+}
+
+// Get the number of URLs not yet sent.
+func (f *shardForwarder) getPending() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.pending
+}
+
+// Add the URLs forwarded by an other shard. If an URL is wrong, return an
+// error and add nothing.
+func (ctx *fetchContext) receiveShardURLs(items []shardURL) error {
+	urls := make([]*url.URL, len(items))
+	for i, item := range items {
+		u, err := url.Parse(item.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("Wrong URL: %q", item.URL)
+		}
+		urls[i] = u
+	}
+
+	for i, item := range items {
+		source := (*url.URL)(nil)
+		if item.Source != "" {
+			source, _ = url.Parse(item.Source)
+		}
+		key := keys.NewURL(urls[i])
+		rules := map[keys.Key]string(nil)
+		if item.Speculative != "" {
+			rules = map[keys.Key]string{key: item.Speculative}
+		}
+		ctx.addURLs(source, map[keys.Key]*url.URL{key: urls[i]}, rules, item.Depth)
+	}
+	ctx.shards.received.Add(int64(len(items)))
+
+	return nil
+}
+
+// Wait the end of a sharded crawl: during two consecutive polls, all
+// shards (their admin API listen the Unix sockets) have no worker, no
+// queued URL and no URL to forward, and all forwarded URLs are received.
+// Return the context error if it's done before.
+func WaitShards(ctx context.Context, sockets []string, poll time.Duration) error {
+	clients := make([]*http.Client, len(sockets))
+	for i, socket := range sockets {
+		clients[i] = unixClient(socket)
+	}
+
+	lastIdle := false
+	lastSent := int64(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+
+		idle := true
+		sent, received := int64(0), int64(0)
+		for _, client := range clients {
+			status, err := getShardStatus(ctx, client)
+			if err != nil {
+				// The shard is not yet started.
+				idle = false
+				break
+			}
+			if status.Workers > 0 || len(status.Hosts) > 0 || status.ShardPending > 0 {
+				idle = false
+			}
+			sent += status.ShardSent
+			received += status.ShardReceived
+		}
+		idle = idle && sent == received
+
+		if idle && lastIdle && sent == lastSent {
+			return nil
+		}
+		lastIdle, lastSent = idle, sent
+	}
+}
+
+func getShardStatus(ctx context.Context, client *http.Client) (status AdminStatus, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://shard/status", nil)
+	if err != nil {
+		return
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Shard status: %d", response.StatusCode)
+		return
+	}
+	err = json.NewDecoder(response.Body).Decode(&status)
+	return
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShardOf(t *testing.T) {
+	assert.Equal(t, 0, ShardOf("https", "example.org", 1))
+	assert.Equal(t, ShardOf("https", "example.org", 7), ShardOf("https", "example.org", 7))
+	for i := 0; i < 100; i++ {
+		shard := ShardOf("https", fmt.Sprintf("h%d.example.org", i), 3)
+		assert.True(t, 0 <= shard && shard < 3)
+	}
+}
+
+func TestCheckShardSockets(t *testing.T) {
+	assert.NoError(t, CheckShardSockets([]string{"db/shard-0/shard.sock", "db/shard-1/shard.sock"}))
+	long := "/" + strings.Repeat("d", maxSocketPath) + "/shard.sock"
+	assert.Error(t, CheckShardSockets([]string{"db/shard-0/shard.sock", long}))
+}
+
+func TestShardForward(t *testing.T) {
+	mainContext, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	sockets := []string{filepath.Join(dir, "0.sock"), filepath.Join(dir, "1.sock")}
+	contexts := make([]*fetchContext, 2)
+	for i := range contexts {
+		_, db, _ := crawldatabase.OpenMemory[Page](nil, "", false)
+		contexts[i] = newFetchContext(mainContext, Config{
+			Logger:       slog.New(sloghandlers.NewNullHandler()),
+			MaxGo:        0, // No worker is launched
+			Shard:        i,
+			Shards:       2,
+			ShardSockets: sockets,
+		}, db)
+		listener, err := net.Listen("unix", sockets[i])
+		assert.NoError(t, err)
+		server := &http.Server{Handler: contexts[i].adminHandler()}
+		go server.Serve(listener)
+		defer server.Close()
+		contexts[i].shards.start(mainContext)
+	}
+
+	// One URL by shard
+	urls := make([]*url.URL, 2)
+	for i := 0; urls[0] == nil || urls[1] == nil; i++ {
+		host := fmt.Sprintf("h%d.example.org", i)
+		urls[ShardOf("https", host, 2)] = &url.URL{Scheme: "https", Host: host, Path: "/"}
+	}
+	source, _ := url.Parse("https://source.example.org/")
+	contexts[0].addURLs(source, map[keys.Key]*url.URL{
+		keys.NewURL(urls[0]): urls[0],
+		keys.NewURL(urls[1]): urls[1],
+	}, map[keys.Key]string{keys.NewURL(urls[1]): "parent-path"}, 1)
+
+	assert.Eventually(t, func() bool { return contexts[1].adminStatus().ShardReceived == 1 }, time.Second*5, time.Millisecond*10)
+	status := contexts[0].adminStatus()
+	assert.Equal(t, 0, status.ShardPending)
+	assert.Equal(t, int64(1), status.ShardSent)
+
+	// Both URLs are known by the first shard, only its URL is planned.
+	for i, ctx := range contexts {
+		assert.Equal(t, crawldatabase.TypeKnow, ctx.db.GetType(keys.NewURL(urls[1])))
+		assert.Len(t, ctx.hosts, 1)
+		assert.NotNil(t, ctx.hosts[createKey("https", urls[i].Host)])
+	}
+	origins, err := contexts[1].db.Origins()
+	assert.NoError(t, err)
+	assert.Equal(t, crawldatabase.Origin{Source: source, Depth: 1, Speculative: "parent-path"}, origins[keys.NewURL(urls[1])])
+
+	// Wait the end of the crawl
+	timeout, cancelTimeout := context.WithTimeout(mainContext, time.Millisecond*100)
+	defer cancelTimeout()
+	assert.Equal(t, context.DeadlineExceeded, WaitShards(timeout, sockets, time.Millisecond*10))
+	for _, ctx := range contexts {
+		ctx.hostsMutex.Lock()
+		ctx.hosts = make(map[string]*host)
+		ctx.hostsMutex.Unlock()
+	}
+	assert.NoError(t, WaitShards(mainContext, sockets, time.Millisecond*10))
+}
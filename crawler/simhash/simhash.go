@@ -0,0 +1,72 @@
+// Compute the SimHash fingerprint of a text, two near-duplicate texts have
+// fingerprints with a small Hamming distance.
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// The number of words of a shingle.
+const shingleLen = 2
+
+// The minimum number of words to compute a fingerprint. Short texts are
+// too often similar.
+const MinWords = 30
+
+// The maximum Hamming distance between two near-duplicate fingerprints.
+const MaxDistance = 3
+
+// Compute the SimHash of the words with shingles of two words.
+// Return zero if there is less than MinWords words.
+func Sum(words []string) uint64 {
+	if len(words) < MinWords {
+		return 0
+	}
+
+	counter := [64]int{}
+	for i := 0; i+shingleLen <= len(words); i++ {
+		h := fnv.New64a()
+		for _, word := range words[i : i+shingleLen] {
+			h.Write([]byte(word))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		for b := range counter {
+			if sum&(1<<b) != 0 {
+				counter[b]++
+			} else {
+				counter[b]--
+			}
+		}
+	}
+
+	fingerprint := uint64(0)
+	for b, c := range counter {
+		if c > 0 {
+			fingerprint |= 1 << b
+		}
+	}
+	return fingerprint
+}
+
+// Split the text into lower case words.
+func Words(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// The Hamming distance between two fingerprints.
+func Distance(a, b uint64) int { return bits.OnesCount64(a ^ b) }
+
+// Return true if a and b are not zero and near.
+func Near(a, b uint64) bool {
+	return a != 0 && b != 0 && Distance(a, b) <= MaxDistance
+}
@@ -0,0 +1,36 @@
+package simhash
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const article = `The municipal council met on Monday evening to discuss the renovation
+of the public library. The project includes a new reading room, a larger
+children's area and the replacement of the heating system. The mayor said
+the works should begin in the spring and last about eighteen months. During
+this period, a temporary library will open in the former post office, with
+reduced opening hours and a smaller collection of books.`
+
+func TestWords(t *testing.T) {
+	assert.Equal(t, []string{"hello", "world", "2023", "été"}, Words("Hello, World! 2023 -- Été"))
+}
+
+func TestSum(t *testing.T) {
+	assert.Zero(t, Sum(Words("Too short text")))
+
+	original := Sum(Words(article))
+	assert.NotZero(t, original)
+	assert.Equal(t, original, Sum(Words(strings.ToUpper(article))))
+
+	// Only a date changes
+	dated := Sum(Words(strings.Replace(article, "Monday", "Tuesday", 1)))
+	assert.True(t, Near(original, dated), Distance(original, dated))
+
+	// An other text
+	other := Sum(Words(strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor. ", 5)))
+	assert.False(t, Near(original, other), Distance(original, other))
+
+	assert.False(t, Near(0, 0))
+}
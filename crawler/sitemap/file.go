@@ -0,0 +1,78 @@
+// Parse sitemap and sitemap index files. The compression (gzip) must be
+// removed before the parsing.
+//
+// Specification: https://www.sitemaps.org/protocol.html
+package sitemap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/charset"
+	"net/url"
+	"strings"
+)
+
+var NotSitemap = errors.New("The root element is not urlset or sitemapindex")
+
+// A parsed sitemap file or a sitemap index.
+type File struct {
+	// True if it's a sitemap index.
+	Index bool
+	// Link to sub sitemaps (from the sitemap index).
+	Sitemaps []url.URL
+	// Link to pages (from the urlset).
+	URLs []url.URL
+}
+
+type xmlLoc struct {
+	Loc string `xml:"loc"`
+}
+
+type xmlFile struct {
+	XMLName  xml.Name
+	URLs     []xmlLoc `xml:"url"`
+	Sitemaps []xmlLoc `xml:"sitemap"`
+}
+
+// Parse the sitemap. Ignore wrong URL (invalid or not http(s)).
+func Parse(data []byte) (*File, error) {
+	decoded := xmlFile{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReader
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	switch decoded.XMLName.Local {
+	case "urlset":
+		return &File{URLs: parseLocs(decoded.URLs)}, nil
+	case "sitemapindex":
+		return &File{Index: true, Sitemaps: parseLocs(decoded.Sitemaps)}, nil
+	default:
+		return nil, NotSitemap
+	}
+}
+
+func parseLocs(locs []xmlLoc) (urls []url.URL) {
+	urls = make([]url.URL, 0, len(locs))
+	for _, loc := range locs {
+		u, _ := url.Parse(strings.TrimSpace(loc.Loc))
+		if u == nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			continue
+		}
+		urls = append(urls, *u)
+	}
+	return
+}
+
+// Return true if the root element of data is a sitemap or a sitemap index.
+func Is(data []byte) bool {
+	switch common.XMLRootName(data, charset.NewReader) {
+	case "urlset", "sitemapindex":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package sitemap
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	file, err := Parse([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url>
+		<loc>https://example.org/</loc>
+		<lastmod>2005-01-01</lastmod>
+	</url>
+	<url><loc> https://example.org/dir/page?a=1 </loc></url>
+	<url><loc>ftp://example.org/file.txt</loc></url>
+	<url><loc>/relative</loc></url>
+</urlset>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &File{URLs: []url.URL{
+		url.URL{Scheme: "https", Host: "example.org", Path: "/"},
+		url.URL{Scheme: "https", Host: "example.org", Path: "/dir/page", RawQuery: "a=1"},
+	}}, file)
+
+	file, err = Parse([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://example.org/sitemap1.xml.gz</loc></sitemap>
+</sitemapindex>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &File{Index: true, Sitemaps: []url.URL{
+		url.URL{Scheme: "https", Host: "example.org", Path: "/sitemap1.xml.gz"},
+	}}, file)
+
+	file, err = Parse([]byte(`<rss version="2.0"></rss>`))
+	assert.Nil(t, file)
+	assert.Equal(t, NotSitemap, err)
+}
+
+func TestIs(t *testing.T) {
+	assert.True(t, Is([]byte(`<?xml version="1.0"?><!-- c --><urlset>`)))
+	assert.True(t, Is([]byte(`<sitemapindex></sitemapindex>`)))
+	assert.False(t, Is([]byte(`<html></html>`)))
+}
@@ -0,0 +1,350 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/warc"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/* EXPORT */
+
+// Export the database into WARC records, sorted by store instant:
+//   - a conversion record for each parsed file (HTML, robots.txt, sitemap
+//     and feed), the content is rendered from the parsed value, it's not
+//     the fetched body;
+//   - a resource record for each favicon, with the fetched data;
+//   - a metadata record for each redirection, canonical alias and
+//     near-duplicate alias, with the field "location", "canonical" or
+//     "duplicate".
+//
+// The known URLs, the errors and the host states are not exported.
+func ExportWARC(db *crawldatabase.Database[Page], w *warc.Writer) error {
+	urls, err := db.URLs()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range db.Entries() {
+		record := (*warc.Record)(nil)
+		switch t := entry.Type; {
+		case crawldatabase.TypeFile <= t && t < crawldatabase.TypeAlias:
+			page, _, err := db.GetValue(entry.Key)
+			if err != nil {
+				return err
+			}
+			record = exportPage(page)
+		case t == crawldatabase.TypeRedirect, t == crawldatabase.TypeAliasCanonical, t == crawldatabase.TypeAliasDuplicate:
+			u, destination := urls[entry.Key], urls[entry.Destination]
+			if u == nil || destination == nil {
+				continue
+			}
+			field := "location"
+			if t == crawldatabase.TypeAliasCanonical {
+				field = "canonical"
+			} else if t == crawldatabase.TypeAliasDuplicate {
+				field = "duplicate"
+			}
+			record = &warc.Record{
+				Type:        warc.TypeMetadata,
+				TargetURI:   u.String(),
+				ContentType: warc.ContentTypeFields,
+				Content:     []byte(field + ": " + destination.String() + "\r\n"),
+			}
+		}
+		if record == nil {
+			continue
+		}
+
+		record.Date = entry.Time
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create the conversion or resource record of the page, nil for a host
+// state. The record has the Content-Type of the content, without HTTP
+// status line nor headers.
+func exportPage(page *Page) *warc.Record {
+	record := &warc.Record{
+		Type:      warc.TypeConversion,
+		TargetURI: page.URL.String(),
+	}
+	body := bytes.Buffer{}
+	switch {
+	case page.Html != nil:
+		record.ContentType = "text/html; charset=utf-8"
+		page.Html.Render(&body)
+	case page.Robots != nil:
+		record.ContentType = "text/plain; charset=utf-8"
+		body.WriteString(page.Robots.String())
+	case page.Sitemap != nil:
+		record.ContentType = "application/xml"
+		sitemap := xmlSitemap{XMLName: xml.Name{Local: "urlset"}, XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+		if page.Sitemap.Index {
+			sitemap.XMLName.Local = "sitemapindex"
+		}
+		for i := range page.Sitemap.URLs {
+			sitemap.URLs = append(sitemap.URLs, xmlSitemapLoc{page.Sitemap.URLs[i].String()})
+		}
+		for i := range page.Sitemap.Sitemaps {
+			sitemap.Sitemaps = append(sitemap.Sitemaps, xmlSitemapLoc{page.Sitemap.Sitemaps[i].String()})
+		}
+		body.WriteString(xml.Header)
+		xml.NewEncoder(&body).Encode(sitemap)
+	case page.Feed != nil:
+		record.ContentType = "application/atom+xml"
+		feed := xmlAtom{Title: page.Feed.Title}
+		for _, item := range page.Feed.Items {
+			entry := xmlAtomEntry{Title: item.Title}
+			entry.Link.Href = item.Link.String()
+			if !item.Date.IsZero() {
+				entry.Updated = item.Date.UTC().Format(time.RFC3339)
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+		body.WriteString(xml.Header)
+		xml.NewEncoder(&body).Encode(feed)
+	case page.Favicon != nil:
+		record.Type = warc.TypeResource
+		record.ContentType = page.Favicon.Type
+		body.Write(page.Favicon.Data)
+	default:
+		return nil
+	}
+	record.Content = body.Bytes()
+
+	return record
+}
+
+type xmlSitemap struct {
+	XMLName  xml.Name
+	XMLNS    string          `xml:"xmlns,attr"`
+	URLs     []xmlSitemapLoc `xml:"url"`
+	Sitemaps []xmlSitemapLoc `xml:"sitemap"`
+}
+
+type xmlSitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+type xmlAtom struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string         `xml:"title"`
+	Entries []xmlAtomEntry `xml:"entry"`
+}
+
+type xmlAtomEntry struct {
+	Title string `xml:"title"`
+	Link  struct {
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Updated string `xml:"updated,omitempty"`
+}
+
+/* IMPORT */
+
+// Import WARC files (compressed or not) into the database of the config,
+// like a crawl without fetch. The response records go through the same
+// pipeline than a fetched page (parsing, filters, canonical, duplicate...),
+// the conversion and resource records from ExportWARC also, as a response
+// with the record Content-Type but without status nor headers;
+// the found URLs are saved as known but they are not crawled. The metadata
+// records from ExportWARC are saved as redirections, canonical aliases and
+// near-duplicate aliases, and the revisit records to an other URL as
+// near-duplicate aliases.
+// The other records are ignored. The database is closed at the end.
+func ImportWARC(mainContext context.Context, config Config, paths ...string) error {
+	_, db, err := config.DBopener(config.Logger, config.DBbase, false)
+	if err != nil {
+		return fmt.Errorf("Open the database with base=%q: %w", config.DBbase, err)
+	}
+	defer db.Close()
+
+	fetchContext := newFetchContext(mainContext, config, db)
+	// No crawl goroutine, the found URLs are only planned.
+	fetchContext.maxGo = 0
+
+	for _, path := range paths {
+		if err := fetchContext.importFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import all records of the WARC file.
+func (ctx *fetchContext) importFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := warc.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("Read WARC %q: %w", path, err)
+	}
+	// The records also contain the HTTP headers.
+	reader.MaxLength = ctx.maxLength + maxWARCHeaders
+
+	count := 0
+	defer func() { ctx.logger.Info("warc.import", "file", path, "records", count) }()
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Read WARC %q: %w", path, err)
+		} else if err := ctx.context.Err(); err != nil {
+			return err
+		}
+		ctx.importRecord(record)
+		count++
+	}
+}
+
+// The maximum size of the HTTP headers of an imported response.
+const maxWARCHeaders = 64 * 1024
+
+// Import one record, see ImportWARC.
+func (ctx *fetchContext) importRecord(record *warc.Record) {
+	u, _ := url.Parse(record.TargetURI)
+	if u == nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return
+	}
+	cleanURL(u)
+	key := keys.NewURL(u)
+
+	switch record.Type {
+	case warc.TypeResponse:
+		if strings.HasPrefix(record.ContentType, "application/http") {
+			ctx.importResponse(key, u, record)
+		}
+	case warc.TypeConversion, warc.TypeResource:
+		ctx.importContent(key, u, record)
+	case warc.TypeMetadata:
+		if record.ContentType != warc.ContentTypeFields || record.Content == nil {
+			return
+		}
+		content := append(record.Content, "\r\n"...)
+		fields, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(content))).ReadMIMEHeader()
+		if location := fields.Get("Location"); location != "" {
+			ctx.importAlias(key, u, location, crawldatabase.TypeRedirect)
+		} else if canonical := fields.Get("Canonical"); canonical != "" {
+			ctx.importAlias(key, u, canonical, crawldatabase.TypeAliasCanonical)
+		} else if duplicate := fields.Get("Duplicate"); duplicate != "" {
+			ctx.importAlias(key, u, duplicate, crawldatabase.TypeAliasDuplicate)
+		}
+	case warc.TypeRevisit:
+		if record.RefersToTargetURI != "" {
+			ctx.importAlias(key, u, record.RefersToTargetURI, crawldatabase.TypeAliasDuplicate)
+		}
+	}
+}
+
+// Parse the HTTP response of the record and save it like a fetched page.
+func (ctx *fetchContext) importResponse(key keys.Key, u *url.URL, record *warc.Record) {
+	ctx.db.AddURL(map[keys.Key]*url.URL{key: u}, map[keys.Key]crawldatabase.Origin{key: {}})
+	if record.Content == nil {
+		ctx.db.SetError(key, crawldatabase.TypeErrorTooLarge, 0)
+		return
+	}
+
+	request := &http.Request{Method: http.MethodGet, URL: u}
+	response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Content)), request)
+	if err != nil {
+		ctx.db.SetSimple(key, crawldatabase.TypeErrorParsing)
+		return
+	}
+	defer response.Body.Close()
+	ctx.importResult(key, u, readResponse(u, response, ctx.maxLength, false, &atomic.Bool{}))
+}
+
+// Save the content of the record like the body of a fetched page, with
+// the record Content-Type. The status is unknown (zero).
+func (ctx *fetchContext) importContent(key keys.Key, u *url.URL, record *warc.Record) {
+	ctx.db.AddURL(map[keys.Key]*url.URL{key: u}, map[keys.Key]crawldatabase.Origin{key: {}})
+	if record.Content == nil || int64(len(record.Content)) > ctx.maxLength {
+		ctx.db.SetError(key, crawldatabase.TypeErrorTooLarge, 0)
+		return
+	}
+
+	body := common.GetBuffer()
+	body.Write(record.Content)
+	ctx.importResult(key, u, fetchResult{
+		body:        body,
+		url:         u,
+		contentType: record.ContentType,
+	})
+}
+
+// Save the imported result like a fetched page, the robots.txt like
+// robotGet().
+func (ctx *fetchContext) importResult(key keys.Key, u *url.URL, result fetchResult) {
+	switch u.Path {
+	case robotsPath:
+		previous, date, _ := ctx.db.GetValue(key)
+		page := robotsPage(*u, previous, robotsHistory(previous, date), &result, ctx.fetcher.UserAgent, time.Now())
+		ctx.db.SetValue(key, page, crawldatabase.TypeFileRobots)
+		return
+	case faviconPath:
+		if result.body != nil {
+			ctx.saveFavicon(key, u, result.body.Bytes(), result.pageResponse())
+			common.RecycleBuffer(result.body)
+		}
+		return
+	}
+
+	for _, filter := range ctx.filterURL {
+		if filter(u) {
+			if result.body != nil {
+				common.RecycleBuffer(result.body)
+			}
+			ctx.db.SetSimple(key, crawldatabase.TypeErrorFilterURL)
+			return
+		}
+	}
+
+	previous := ctx.getPrevious(key)
+	ctx.saveResult(key, u, 0, previous, result)
+}
+
+// Save u as a redirection or an alias (type t) to the target, a URL
+// relative to u.
+func (ctx *fetchContext) importAlias(key keys.Key, u *url.URL, target string, t byte) {
+	destination, _ := u.Parse(target)
+	if destination == nil || destination.Scheme != "https" && destination.Scheme != "http" {
+		return
+	}
+	cleanURL(destination)
+	destinationKey := keys.NewURL(destination)
+	if destinationKey == key {
+		return
+	}
+
+	ctx.db.AddURL(map[keys.Key]*url.URL{key: u}, map[keys.Key]crawldatabase.Origin{key: {}})
+	ctx.addURLs(u, map[keys.Key]*url.URL{destinationKey: destination}, nil, 1)
+	if t == crawldatabase.TypeRedirect {
+		ctx.db.SetRedirect(key, destinationKey)
+	} else {
+		ctx.db.SetAlias(key, destinationKey, t)
+	}
+}
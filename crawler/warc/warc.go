@@ -0,0 +1,251 @@
+// Read and write WARC files (Web ARChive). The files can be compressed, with
+// one gzip member by record.
+//
+// Specification: https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The version written in each record.
+const Version = "WARC/1.1"
+
+// The record types (WARC-Type field).
+const (
+	TypeWarcinfo   = "warcinfo"
+	TypeResponse   = "response"
+	TypeResource   = "resource"
+	TypeRequest    = "request"
+	TypeMetadata   = "metadata"
+	TypeRevisit    = "revisit"
+	TypeConversion = "conversion"
+)
+
+// The content types of the record blocks.
+const (
+	// An HTTP response, with the status line, the headers and the body.
+	ContentTypeResponse = "application/http;msgtype=response"
+	// Lines of "name: value", like the WARC header.
+	ContentTypeFields = "application/warc-fields"
+)
+
+// The profile of a revisit record whose payload is identical to the
+// payload of the refered record.
+const ProfileIdenticalPayload = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+
+var NotWARC = errors.New("The record do not begin with WARC/")
+
+// One WARC record.
+type Record struct {
+	// The WARC-Type field, like TypeResponse.
+	Type string
+	// The WARC-Record-ID field (like "<urn:uuid:...>"), generated on write
+	// if empty.
+	ID string
+	// The WARC-Date field, the current time on write if zero.
+	Date time.Time
+	// The WARC-Target-URI field.
+	TargetURI string
+	// The Content-Type field of the block, like ContentTypeResponse.
+	ContentType string
+
+	// Fields of a revisit record: WARC-Profile, WARC-Refers-To and
+	// WARC-Refers-To-Target-URI.
+	Profile           string
+	RefersTo          string
+	RefersToTargetURI string
+
+	// All the fields of a read record. On write, the fields that are not
+	// in the above attributes are also written.
+	Fields textproto.MIMEHeader
+
+	// The block length (Content-Length field) and content. Content is nil
+	// if the record is bigger than Reader.MaxLength.
+	Length  int64
+	Content []byte
+}
+
+/* WRITE */
+
+// A WARC file writer.
+type Writer struct {
+	w        io.Writer
+	compress bool
+}
+
+// Create a new writer. If compress, each record is a gzip member.
+func NewWriter(w io.Writer, compress bool) *Writer {
+	return &Writer{w: w, compress: compress}
+}
+
+// Write the record, generate the ID and the date if they are empty. The
+// WARC-Block-Digest is computed from the content.
+func (w *Writer) Write(record *Record) error {
+	if record.ID == "" {
+		id, err := NewID()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+	}
+	if record.Date.IsZero() {
+		record.Date = time.Now()
+	}
+	record.Length = int64(len(record.Content))
+
+	buff := bytes.Buffer{}
+	buff.WriteString(Version + "\r\n")
+	writeField := func(name, value string) {
+		if value != "" {
+			buff.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	writeField("WARC-Type", record.Type)
+	writeField("WARC-Record-ID", record.ID)
+	writeField("WARC-Date", record.Date.UTC().Format(time.RFC3339))
+	writeField("WARC-Target-URI", record.TargetURI)
+	writeField("WARC-Profile", record.Profile)
+	writeField("WARC-Refers-To", record.RefersTo)
+	writeField("WARC-Refers-To-Target-URI", record.RefersToTargetURI)
+	writeField("WARC-Block-Digest", blockDigest(record.Content))
+	writeField("Content-Type", record.ContentType)
+
+	otherFields := make([]string, 0, len(record.Fields))
+	for name := range record.Fields {
+		switch textproto.CanonicalMIMEHeaderKey(name) {
+		case "Warc-Type", "Warc-Record-Id", "Warc-Date", "Warc-Target-Uri",
+			"Warc-Profile", "Warc-Refers-To", "Warc-Refers-To-Target-Uri",
+			"Warc-Block-Digest", "Content-Type", "Content-Length":
+			continue
+		}
+		otherFields = append(otherFields, name)
+	}
+	sort.Strings(otherFields)
+	for _, name := range otherFields {
+		for _, value := range record.Fields[name] {
+			writeField(name, value)
+		}
+	}
+
+	buff.WriteString("Content-Length: " + strconv.Itoa(len(record.Content)) + "\r\n\r\n")
+	buff.Write(record.Content)
+	buff.WriteString("\r\n\r\n")
+
+	if !w.compress {
+		_, err := buff.WriteTo(w.w)
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(w.w)
+	if _, err := buff.WriteTo(gzipWriter); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// Generate a new record ID, it's a random UUID.
+func NewID() (string, error) {
+	uuid := [16]byte{}
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0F | 0x40
+	uuid[8] = uuid[8]&0x3F | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", uuid[:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// The WARC-Block-Digest value: the SHA-1 of the content in base 32.
+func blockDigest(content []byte) string {
+	hash := sha1.Sum(content)
+	return "sha1:" + base32.StdEncoding.EncodeToString(hash[:])
+}
+
+/* READ */
+
+// A WARC file reader.
+type Reader struct {
+	r *bufio.Reader
+
+	// If positive, the content of bigger records is not read.
+	MaxLength int64
+}
+
+// Create a new reader. The input can be compressed with gzip.
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1F, 0x8B}) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReader(gzipReader)
+	}
+	return &Reader{r: buffered}, nil
+}
+
+// Read the next record. Return io.EOF at the end of the file.
+func (r *Reader) Next() (*Record, error) {
+	// Version line, skip the empty lines between records.
+	line := ""
+	for line == "" {
+		l, err := r.r.ReadString('\n')
+		if err == io.EOF && l == "" {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSpace(l)
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, NotWARC
+	}
+
+	fields, err := textproto.NewReader(r.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("Read WARC header: %w", err)
+	}
+
+	length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("Wrong WARC Content-Length: %q", fields.Get("Content-Length"))
+	}
+
+	record := &Record{
+		Type:              fields.Get("WARC-Type"),
+		ID:                fields.Get("WARC-Record-ID"),
+		TargetURI:         strings.Trim(fields.Get("WARC-Target-URI"), "<>"),
+		ContentType:       fields.Get("Content-Type"),
+		Profile:           fields.Get("WARC-Profile"),
+		RefersTo:          fields.Get("WARC-Refers-To"),
+		RefersToTargetURI: strings.Trim(fields.Get("WARC-Refers-To-Target-URI"), "<>"),
+		Fields:            fields,
+		Length:            length,
+	}
+	record.Date, _ = time.Parse(time.RFC3339, fields.Get("WARC-Date"))
+
+	if r.MaxLength > 0 && length > r.MaxLength {
+		_, err = io.CopyN(io.Discard, r.r, length)
+	} else {
+		record.Content = make([]byte, length)
+		_, err = io.ReadFull(r.r, record.Content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Read WARC block: %w", err)
+	}
+
+	return record, nil
+}
@@ -0,0 +1,109 @@
+package warc
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	date := time.Date(2023, time.March, 4, 10, 20, 30, 0, time.UTC)
+	records := []*Record{
+		&Record{
+			Type:        TypeResponse,
+			ID:          "<urn:uuid:1>",
+			Date:        date,
+			TargetURI:   "https://example.org/",
+			ContentType: ContentTypeResponse,
+			Content:     []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<p>Hello</p>"),
+		},
+		&Record{
+			Type:              TypeRevisit,
+			ID:                "<urn:uuid:2>",
+			Date:              date,
+			TargetURI:         "https://example.org/index.html",
+			Profile:           ProfileIdenticalPayload,
+			RefersTo:          "<urn:uuid:1>",
+			RefersToTargetURI: "https://example.org/",
+			Fields:            textproto.MIMEHeader{"X-Custom": []string{"value"}},
+		},
+	}
+
+	for _, compress := range []bool{false, true} {
+		buff := bytes.Buffer{}
+		w := NewWriter(&buff, compress)
+		for _, record := range records {
+			assert.NoError(t, w.Write(record))
+		}
+
+		r, err := NewReader(&buff)
+		assert.NoError(t, err)
+		for _, expected := range records {
+			record, err := r.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, expected.Type, record.Type)
+			assert.Equal(t, expected.ID, record.ID)
+			assert.Equal(t, expected.Date, record.Date)
+			assert.Equal(t, expected.TargetURI, record.TargetURI)
+			assert.Equal(t, expected.ContentType, record.ContentType)
+			assert.Equal(t, expected.Profile, record.Profile)
+			assert.Equal(t, expected.RefersTo, record.RefersTo)
+			assert.Equal(t, expected.RefersToTargetURI, record.RefersToTargetURI)
+			assert.Equal(t, int64(len(expected.Content)), record.Length)
+			assert.Equal(t, string(expected.Content), string(record.Content))
+			assert.Equal(t, blockDigest(expected.Content), record.Fields.Get("WARC-Block-Digest"))
+		}
+		assert.Equal(t, "value", records[1].Fields.Get("X-Custom"))
+		record, err := r.Next()
+		assert.Nil(t, record)
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestReadWget(t *testing.T) {
+	r, err := NewReader(strings.NewReader("WARC/1.0\r\n" +
+		"WARC-Type: warcinfo\r\n" +
+		"Content-Type: application/warc-fields\r\n" +
+		"Content-Length: 14\r\n" +
+		"\r\n" +
+		"software: Wget\r\n\r\n" +
+		"WARC/1.0\r\n" +
+		"WARC-Type: response\r\n" +
+		"WARC-Target-URI: <https://example.org/big>\r\n" +
+		"Content-Length: 10\r\n" +
+		"\r\n" +
+		"0123456789\r\n\r\n"))
+	assert.NoError(t, err)
+
+	record, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeWarcinfo, record.Type)
+	assert.Equal(t, "software: Wget", string(record.Content))
+
+	r.MaxLength = 5
+
+	record, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TypeResponse, record.Type)
+	assert.Equal(t, "https://example.org/big", record.TargetURI)
+	assert.Equal(t, int64(10), record.Length)
+	assert.Nil(t, record.Content)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+
+	r, err = NewReader(strings.NewReader("HTTP/1.1 200 OK\r\n"))
+	assert.NoError(t, err)
+	_, err = r.Next()
+	assert.Equal(t, NotWARC, err)
+}
+
+func TestNewID(t *testing.T) {
+	id, err := NewID()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^<urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}>$`, id)
+}
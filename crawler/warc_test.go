@@ -0,0 +1,191 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/crawler/warc"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWARC(t *testing.T) {
+	_, logHandler := sloghandlers.NewHandlerRecords(slog.DebugLevel)
+	logger := slog.New(logHandler)
+	config := Config{
+		Input: common.ParseURLs("https://example.org/"),
+		FilterURL: []func(*url.URL) bool{
+			func(u *url.URL) bool { return u.Host != "example.org" },
+		},
+		FilterPage: []func(*htmlnode.Root) bool{
+			func(page *htmlnode.Root) bool { return page.Meta.Langage != "en" },
+		},
+		MaxLength: 15_000_000,
+		MaxGo:     1,
+		Fetcher: Fetcher{
+			RoundTripper: datatestRoundTripper{},
+			UserAgent:    "IstyTest/1.0",
+		},
+		Logger: logger,
+	}
+
+	// Crawl and export
+	_, crawled, _ := crawldatabase.OpenMemory[Page](logger, "", false)
+	config.DBopener = func(*slog.Logger, string, bool) ([]*url.URL, *crawldatabase.Database[Page], error) {
+		return nil, crawled, nil
+	}
+	assert.NoError(t, Crawl(context.Background(), config))
+
+	path := filepath.Join(t.TempDir(), "crawl.warc.gz")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, ExportWARC(crawled, warc.NewWriter(file, true)))
+	assert.NoError(t, file.Close())
+
+	// The parsed pages are exported as conversion, not as fetched response.
+	file, err = os.Open(path)
+	assert.NoError(t, err)
+	reader, err := warc.NewReader(file)
+	assert.NoError(t, err)
+	for record, err := reader.Next(); err == nil; record, err = reader.Next() {
+		assert.NotEqual(t, warc.TypeResponse, record.Type)
+		if record.TargetURI == "https://example.org/" {
+			assert.Equal(t, warc.TypeConversion, record.Type)
+			assert.Equal(t, "text/html; charset=utf-8", record.ContentType)
+		}
+	}
+	assert.NoError(t, file.Close())
+
+	// Import
+	config.DBopener = crawldatabase.Open[Page]
+	config.DBbase = t.TempDir()
+	assert.NoError(t, ImportWARC(context.Background(), config, path))
+	_, imported, err := crawldatabase.Open[Page](logger, config.DBbase, false)
+	assert.NoError(t, err)
+	defer imported.Close()
+
+	for _, entry := range crawled.Entries() {
+		if crawldatabase.TypeFile <= entry.Type && entry.Type < crawldatabase.TypeFileHost {
+			assert.Equal(t, entry.Type, imported.GetType(entry.Key))
+		}
+	}
+	assert.Equal(t, crawled.Redirections(), imported.Redirections())
+
+	page, _, err := imported.GetValue(keys.NewString("https://example.org/"))
+	assert.NoError(t, err)
+	expected, _, _ := crawled.GetValue(keys.NewString("https://example.org/"))
+	assert.Equal(t, expected.Html.Meta, page.Html.Meta)
+	assert.Equal(t, expected.SimHash, page.SimHash)
+
+	robots, _, err := imported.GetValue(keys.NewString("https://example.org/robots.txt"))
+	assert.NoError(t, err)
+	expected, _, _ = crawled.GetValue(keys.NewString("https://example.org/robots.txt"))
+	assert.Equal(t, expected.Robots, robots.Robots)
+
+	// The found URLs are known, not crawled.
+	assert.Equal(t, crawldatabase.TypeKnow, imported.GetType(keys.NewString("https://www.example.org/")))
+}
+
+func TestImportWARCWget(t *testing.T) {
+	body := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&body)
+	gzipWriter.Write([]byte(`<!DOCTYPE html><html lang="en"><head><title>Hello</title></head><body><a href="/a">A</a></body></html>`))
+	gzipWriter.Close()
+
+	path := filepath.Join(t.TempDir(), "wget.warc")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	w := warc.NewWriter(file, false)
+	assert.NoError(t, w.Write(&warc.Record{Type: warc.TypeWarcinfo, Content: []byte("software: Wget/1.21\r\n")}))
+	assert.NoError(t, w.Write(&warc.Record{Type: warc.TypeRequest, TargetURI: "https://example.org/"}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:        warc.TypeResponse,
+		TargetURI:   "https://example.org/",
+		ContentType: warc.ContentTypeResponse,
+		Content:     []byte("HTTP/1.1 301 Moved Permanently\r\nLocation: /home\r\nContent-Length: 0\r\n\r\n"),
+	}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:        warc.TypeResponse,
+		TargetURI:   "https://example.org/home",
+		ContentType: warc.ContentTypeResponse,
+		Content: append(append([]byte("HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/html\r\n"+
+			"Content-Encoding: gzip\r\n"+
+			"Transfer-Encoding: chunked\r\n\r\n"+
+			strconv.FormatInt(int64(body.Len()), 16)+"\r\n"),
+			body.Bytes()...), "\r\n0\r\n\r\n"...),
+	}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:        warc.TypeResponse,
+		TargetURI:   "https://example.org/gone",
+		ContentType: warc.ContentTypeResponse,
+		Content:     []byte("HTTP/1.1 410 Gone\r\n\r\n"),
+	}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:              warc.TypeRevisit,
+		TargetURI:         "https://example.org/index.html",
+		Profile:           warc.ProfileIdenticalPayload,
+		RefersToTargetURI: "https://example.org/home",
+	}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:        warc.TypeResponse,
+		TargetURI:   "https://example.org/robots.txt",
+		ContentType: warc.ContentTypeResponse,
+		Content:     []byte("HTTP/1.1 503 Service Unavailable\r\nContent-Length: 0\r\n\r\n"),
+	}))
+	assert.NoError(t, w.Write(&warc.Record{
+		Type:        warc.TypeMetadata,
+		TargetURI:   "https://example.org/copy",
+		ContentType: warc.ContentTypeFields,
+		Content:     []byte("duplicate: /home\r\n"),
+	}))
+	assert.NoError(t, file.Close())
+
+	_, logHandler := sloghandlers.NewHandlerRecords(slog.DebugLevel)
+	logger := slog.New(logHandler)
+	base := t.TempDir()
+	assert.NoError(t, ImportWARC(context.Background(), Config{
+		DBopener:  crawldatabase.Open[Page],
+		DBbase:    base,
+		MaxLength: 1_000_000,
+		Logger:    logger,
+	}, path))
+	_, db, err := crawldatabase.Open[Page](logger, base, false)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	home := keys.NewString("https://example.org/home")
+	assert.Equal(t, crawldatabase.TypeRedirect, db.GetType(keys.NewString("https://example.org/")))
+	assert.Equal(t, crawldatabase.TypeFileHTML, db.GetType(home))
+	assert.Equal(t, crawldatabase.TypeErrorGone, db.GetType(keys.NewString("https://example.org/gone")))
+	assert.Equal(t, crawldatabase.TypeKnow, db.GetType(keys.NewString("https://example.org/a")))
+	assert.Equal(t, map[keys.Key]keys.Key{
+		keys.NewString("https://example.org/"):           home,
+		keys.NewString("https://example.org/index.html"): home,
+		keys.NewString("https://example.org/copy"):       home,
+	}, db.Redirections())
+	assert.Equal(t, crawldatabase.TypeAliasDuplicate, db.GetType(keys.NewString("https://example.org/copy")))
+
+	page, _, err := db.GetValue(home)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", page.Html.Meta.Title)
+
+	// An unreachable robots.txt disallows all, and it's fetched again.
+	page, _, err = db.GetValue(keys.NewString("https://example.org/robots.txt"))
+	assert.NoError(t, err)
+	assert.Nil(t, page.Robots)
+	assert.Equal(t, 503, page.Response.Status)
+	assert.False(t, page.RobotsFetch.Unreachable.IsZero())
+	assert.False(t, time.Now().Before(page.RobotsFetch.Expires))
+}
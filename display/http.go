@@ -3,11 +3,16 @@ package display
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/HuguesGuilleus/isty-search/keys"
 	"github.com/HuguesGuilleus/isty-search/search"
 	"golang.org/x/exp/slog"
 )
 
+// The path prefix of the hosts favicon, followed by the favicon key.
+const faviconPrefix = "/favicon/"
+
 func Handler(logger *slog.Logger, db *search.DB) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -50,12 +55,38 @@ func Handler(logger *slog.Logger, db *search.DB) http.Handler {
 			sendResult(w, r, db, q, page)
 
 		default:
+			if strings.HasPrefix(r.URL.Path, faviconPrefix) {
+				serveFavicon(w, r, logger, db)
+				return
+			}
 			logger.Info("serv.404", "url", r.URL.String())
 			http.NotFound(w, r)
 		}
 	})
 }
 
+// Serve the favicon of a host from the crawler database.
+func serveFavicon(w http.ResponseWriter, r *http.Request, logger *slog.Logger, db *search.DB) {
+	key, err := keys.Parse(strings.TrimPrefix(r.URL.Path, faviconPrefix))
+	if err != nil {
+		logger.Info("serv.favicon.wrongkey", "url", r.URL.String())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	favicon, err := db.Favicon(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The icon can be a SVG, so we block all embeded scripts.
+	w.Header().Add("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Header().Add("X-Content-Type-Options", "nosniff")
+	w.Header().Add("Cache-Control", "public, max-age=86400")
+	serveStatic(w, favicon.Type, favicon.Data)
+}
+
 func serveStatic(w http.ResponseWriter, mime string, content []byte) {
 	w.Header().Add("Content-Length", strconv.Itoa(len(content)))
 	w.Header().Add("Content-Type", mime)
@@ -0,0 +1,51 @@
+package display
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	"github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/metrics"
+	"github.com/HuguesGuilleus/isty-search/search"
+	"github.com/HuguesGuilleus/isty-search/sloghandlers"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeFavicon(t *testing.T) {
+	_, crawlerDB, _ := crawldatabase.OpenMemory[crawler.Page](nil, "", false)
+	key := crawler.FaviconKey("https", "example.org")
+	crawlerDB.SetValue(key, &crawler.Page{
+		Favicon: &crawler.Favicon{Type: "image/x-icon", Data: imageFavicon},
+	}, crawldatabase.TypeFileFavicon)
+	handler := Handler(slog.New(sloghandlers.NewNullHandler()), &search.DB{CrawlerDB: crawlerDB})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	recorder := serve("/favicon/" + key.String())
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "image/x-icon", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, imageFavicon, recorder.Body.Bytes())
+
+	assert.Equal(t, http.StatusNotFound, serve("/favicon/"+crawler.FaviconKey("https", "unknown.org").String()).Code)
+	assert.Equal(t, http.StatusBadRequest, serve("/favicon/yolo").Code)
+}
+
+func TestSearchMetrics(t *testing.T) {
+	handler := Handler(slog.New(sloghandlers.NewNullHandler()), search.FakeDB())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/result?q=word", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	metrics.Default.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "\nisty_search_requests_total{outcome=\"ok\"} 1\n")
+	assert.Contains(t, body, "\nisty_search_results_bucket{le=\"100\"} 1\n")
+	assert.Contains(t, body, "\nisty_search_duration_seconds_count 1\n")
+}
@@ -3,27 +3,54 @@ package display
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/HuguesGuilleus/isty-search/metrics"
 	"github.com/HuguesGuilleus/isty-search/search"
 )
 
+var (
+	metricSearch = metrics.Default.NewCounter("isty_search_requests_total",
+		"The number of search requests by outcome (ok or error).",
+		"outcome")
+	metricSearchDuration = metrics.Default.NewHistogram("isty_search_duration_seconds",
+		"The duration of the search, without the HTML rendering.",
+		0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5)
+	metricSearchResults = metrics.Default.NewHistogram("isty_search_results",
+		"The number of results of a search.",
+		0, 1, 10, 100, 1000, 10_000, 100_000)
+)
+
 func sendResult(w http.ResponseWriter, r *http.Request, db *search.DB, query string, p int) {
+	begin := time.Now()
 	result, err := db.Search(query, p)
+	metricSearchDuration.Observe(time.Since(begin).Seconds())
 	if err != nil {
+		metricSearch.Inc("error")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	metricSearch.Inc("ok")
+	metricSearchResults.Observe(float64(result.NumberOfResults))
 
 	nodeResults := make([]node, len(result.Results))
 	for i, p := range result.Results {
 		u := p.URL.String()
+		info := []node(nil)
+		if p.Favicon != (keys.Key{}) {
+			info = append(info, nap("img.search-results-item-info-icon", []string{
+				`src="` + faviconPrefix + p.Favicon.String() + `"`,
+				"width=16", "height=16",
+				`alt=""`,
+			}))
+		}
+		info = append(info, nt("span.search-results-item-info-url", limitString(u, 70)))
 		nodeResults[i] = np("li.search-results-item",
 			nap("a.search-results-item", []string{`href="` + u + `"`},
 				nt("div.search-results-item-title", limitString(p.Title, 50)),
-				np("div.search-results-item-info",
-					nt("span.search-results-item-info-url", limitString(u, 70)),
-				),
+				np("div.search-results-item-info", info...),
 				nt("div.search-results-item-desc", limitString(p.Description, 150)),
 			),
 		)
@@ -0,0 +1,88 @@
+package index
+
+import (
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	"github.com/HuguesGuilleus/isty-search/keys"
+)
+
+// The weight of a word in a link anchor text, a word in the page body has
+// the weight 1.
+const AnchorWeight float32 = 3
+
+// Collect the words of the link anchor texts, to credit them to the target
+// page. The links with rel=nofollow and the links to the page itself are
+// ignored.
+type Anchors struct {
+	// The redirection and alias map (see crawldatabase.Redirections()),
+	// used to give the words to the canonical page.
+	redirection map[keys.Key]keys.Key
+	// Counter of the words: word -> target page -> count.
+	words map[string]map[keys.Key]float32
+	// The processed pages, only known pages get the words.
+	pages map[keys.Key]bool
+}
+
+func NewAnchors(redirection map[keys.Key]keys.Key) *Anchors {
+	return &Anchors{
+		redirection: redirection,
+		words:       make(map[string]map[keys.Key]float32),
+		pages:       make(map[keys.Key]bool),
+	}
+}
+
+func (anchors *Anchors) Process(page *crawler.Page) {
+	source := anchors.resolve(keys.NewURL(&page.URL))
+	anchors.pages[source] = true
+
+	for _, link := range page.GetLinks() {
+		if link.NoFollow || link.Text == "" {
+			continue
+		}
+		target := anchors.resolve(keys.NewURL(link.URL))
+		if target == source {
+			continue
+		}
+		for _, word := range GetVocab(link.Text) {
+			counter := anchors.words[word]
+			if counter == nil {
+				counter = make(map[keys.Key]float32)
+				anchors.words[word] = counter
+			}
+			counter[target]++
+		}
+	}
+}
+
+func (anchors *Anchors) resolve(key keys.Key) keys.Key {
+	if target, ok := anchors.redirection[key]; ok {
+		return target
+	}
+	return key
+}
+
+// Add the anchor words to the reverse index, multiplied by the weight.
+// Must be call after all Anchors.Process() and before ReverseIndex.Sort().
+func (anchors *Anchors) AddTo(index ReverseIndex, weight float32) {
+	for word, counter := range anchors.words {
+		wordKey := keys.NewString(word)
+		items := index[wordKey]
+		positions := make(map[keys.Key]int, len(items))
+		for i, item := range items {
+			positions[item.Key] = i
+		}
+
+		for target, count := range counter {
+			if !anchors.pages[target] {
+				continue
+			} else if i, ok := positions[target]; ok {
+				items[i].F32 += count * weight
+			} else {
+				items = append(items, KeyFloat32{target, count * weight})
+			}
+		}
+
+		if len(items) > 0 {
+			index[wordKey] = items
+		}
+	}
+}
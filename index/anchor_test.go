@@ -0,0 +1,53 @@
+package index
+
+import (
+	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
+	"github.com/HuguesGuilleus/isty-search/keys"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAnchors(t *testing.T) {
+	parse := func(u, html string) *crawler.Page {
+		root, err := htmlnode.Parse([]byte(html))
+		assert.NoError(t, err)
+		return &crawler.Page{URL: *common.ParseURL(u), Html: root}
+	}
+
+	pageA := keys.NewString("https://example.com/a")
+	pageB := keys.NewString("https://example.com/b")
+	pageR := keys.NewString("https://example.com/r")
+
+	index := ReverseIndex{
+		keys.NewString("portal"): []KeyFloat32{{pageB, 1}},
+	}
+	anchors := NewAnchors(map[keys.Key]keys.Key{pageR: pageB})
+	pages := []*crawler.Page{
+		parse("https://example.com/a", `<html><head></head><body>
+			<a href="/b">Student portal</a>
+			<a href="/r">portal</a>
+			<a href="/b" rel="ugc">spam</a>
+			<a href="/a">self</a>
+			<a href="/unknown">unknown</a>
+		</body></html>`),
+		parse("https://example.com/b", `<html><head></head><body>
+			<a href="/a">Home</a>
+		</body></html>`),
+	}
+	for _, page := range pages {
+		anchors.Process(page)
+		index.Process(page)
+	}
+	anchors.AddTo(index, 3)
+	index.Sort()
+
+	// The anchor text is also in the body of the source page.
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 2}, {pageB, 7}}, index[keys.NewString("portal")])
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 1}, {pageB, 3}}, index[keys.NewString("student")])
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 3}, {pageB, 1}}, index[keys.NewString("home")])
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 1}}, index[keys.NewString("spam")])
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 1}}, index[keys.NewString("self")])
+	assert.ElementsMatch(t, []KeyFloat32{{pageA, 1}}, index[keys.NewString("unknown")])
+}
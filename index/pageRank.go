@@ -15,7 +15,8 @@ import (
 type Links struct {
 	// All global link (page1 from domain-a.net --> page2 from domain-b.net)
 	globalLinks map[keys.Key][]keys.Key
-	// The redirection map
+	// The redirection and alias map (see crawldatabase.Redirections()),
+	// used to give the link to the canonical page.
 	redirection map[keys.Key]keys.Key
 }
 
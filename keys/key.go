@@ -3,6 +3,7 @@ package keys
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"net/url"
 )
 
@@ -20,6 +21,16 @@ func NewString(s string) Key { return Key(sha256.Sum256([]byte(s))) }
 // Return the value in hexadecimal
 func (key Key) String() string { return hex.EncodeToString(key[:]) }
 
+// Parse a key from his hexadecimal representation.
+func Parse(s string) (key Key, err error) {
+	if len(s) != Len*2 {
+		return Key{}, fmt.Errorf("Parse key: need %d hexadecimal characters, get %d", Len*2, len(s))
+	} else if _, err := hex.Decode(key[:], []byte(s)); err != nil {
+		return Key{}, fmt.Errorf("Parse key: %w", err)
+	}
+	return
+}
+
 func (k1 *Key) Less(k2 *Key) bool {
 	for i, v1 := range *k1 {
 		v2 := (*k2)[i]
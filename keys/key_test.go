@@ -13,6 +13,17 @@ func TestKey(t *testing.T) {
 	)
 }
 
+func TestParse(t *testing.T) {
+	key, err := Parse("3dd298199842308839e8f2d7e8f6585154e3ce49e77ccc45340a5b064eacddfe")
+	assert.NoError(t, err)
+	assert.Equal(t, NewString("https://www.google.com/search/howsearchworks/?fg=1"), key)
+
+	_, err = Parse("3dd2")
+	assert.Error(t, err)
+	_, err = Parse("zzd298199842308839e8f2d7e8f6585154e3ce49e77ccc45340a5b064eacddfe")
+	assert.Error(t, err)
+}
+
 func TestCompare(t *testing.T) {
 	assert.True(t, (&Key{0, 1, 3}).Less(&Key{0, 1, 5}))
 	assert.False(t, (&Key{}).Less(&Key{}))
@@ -0,0 +1,228 @@
+// Simple metrics (counters, gauges and histograms) exposed in the
+// Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The registry used by all the packages.
+var Default = NewRegistry()
+
+// A set of metrics, it's a http.Handler that write all metrics.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Add the metric, replace the metric with the same name.
+func (r *Registry) register(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics[name] = m
+}
+
+// Write all metrics, sorted by name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	buff := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buff)
+	}
+	buff.Flush()
+}
+
+/* COUNTER */
+
+// A counter, with optional labels.
+type Counter struct {
+	name, help string
+	labels     []string
+
+	mutex sync.Mutex
+	// The key is the label values joined by labelSeparator.
+	values map[string]float64
+}
+
+const labelSeparator = "\xff"
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+	r.register(name, c)
+	return c
+}
+
+// Add one, the label values are in the same order of the labels.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add v (must be positive), the label values are in the same order of the
+// labels.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[strings.Join(labelValues, labelSeparator)] += v
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeSample(w, c.name, c.labels, strings.Split(key, labelSeparator), c.values[key])
+	}
+}
+
+/* GAUGE */
+
+// A gauge, the values are get by a function on each write.
+type gaugeFunc struct {
+	name, help string
+	labels     []string
+	// Call add for each value.
+	collect func(add func(v float64, labelValues ...string))
+}
+
+// Create a gauge, on each write, collect is called and it call add for
+// each value.
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(add func(v float64, labelValues ...string))) {
+	r.register(name, &gaugeFunc{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		collect: collect,
+	})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	g.collect(func(v float64, labelValues ...string) {
+		writeSample(w, g.name, g.labels, labelValues, v)
+	})
+}
+
+/* HISTOGRAM */
+
+// A histogram of observed values.
+type Histogram struct {
+	name, help string
+	// The upper bounds of the buckets, sorted.
+	buckets []float64
+
+	mutex  sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Create a histogram with the buckets upper bounds (sorted, without +Inf).
+func (r *Registry) NewHistogram(name, help string, buckets ...float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	cumulative := uint64(0)
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		writeSample(w, h.name+"_bucket", []string{"le"}, []string{formatFloat(bound)}, float64(cumulative))
+	}
+	writeSample(w, h.name+"_bucket", []string{"le"}, []string{"+Inf"}, float64(h.count))
+	writeSample(w, h.name+"_sum", nil, nil, h.sum)
+	writeSample(w, h.name+"_count", nil, nil, float64(h.count))
+}
+
+/* FORMAT */
+
+func writeHeader(w *bufio.Writer, name, help, t string) {
+	w.WriteString("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help) + "\n")
+	w.WriteString("# TYPE " + name + " " + t + "\n")
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			value := ""
+			if i < len(labelValues) {
+				value = labelValues[i]
+			}
+			w.WriteString(label + `="` + labelReplacer.Replace(value) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
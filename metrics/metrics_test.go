@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	fetch := r.NewCounter("fetch_total", "The fetch count.", "status", "type")
+	fetch.Inc("200", "ok")
+	fetch.Inc("200", "ok")
+	fetch.Add(3, "0", `error"network`)
+
+	r.NewGaugeFunc("db_entries", "Entries\nby type.", []string{"type"}, func(add func(float64, ...string)) {
+		add(5, "html")
+		add(1.5, "robots")
+	})
+	r.NewGaugeFunc("goroutines", "The goroutines.", nil, func(add func(float64, ...string)) {
+		add(2)
+	})
+
+	duration := r.NewHistogram("duration_seconds", "The duration.", 0.1, 1)
+	duration.Observe(0.05)
+	duration.Observe(0.1)
+	duration.Observe(0.5)
+	duration.Observe(10)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP db_entries Entries\nby type.
+# TYPE db_entries gauge
+db_entries{type="html"} 5
+db_entries{type="robots"} 1.5
+# HELP duration_seconds The duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 2
+duration_seconds_bucket{le="1"} 3
+duration_seconds_bucket{le="+Inf"} 4
+duration_seconds_sum 10.65
+duration_seconds_count 4
+# HELP fetch_total The fetch count.
+# TYPE fetch_total counter
+fetch_total{status="0",type="error\"network"} 3
+fetch_total{status="200",type="ok"} 2
+# HELP goroutines The goroutines.
+# TYPE goroutines gauge
+goroutines 2
+`, w.Body.String())
+}
@@ -44,6 +44,9 @@ type PageResult struct {
 	// The page key and his URL.
 	Key keys.Key
 	URL url.URL
+	// The key of the favicon of the page host, zero if the host has no
+	// stored favicon.
+	Favicon keys.Key
 	// Metadata of the page.
 	Title       string
 	Description string
@@ -66,6 +69,9 @@ func FakeDB() *DB {
 			Html: &htmlnode.Root{Meta: htmlnode.Meta{Title: "page:" + istr, Description: "desc:" + istr}},
 		}, crawldatabase.TypeFileHTML)
 	}
+	crawlerDB.SetValue(crawler.FaviconKey("https", "exemple.org"), &crawler.Page{
+		Favicon: &crawler.Favicon{Type: "image/x-icon"},
+	}, crawldatabase.TypeFileFavicon)
 
 	return &DB{
 		CrawlerDB: crawlerDB,
@@ -114,10 +120,27 @@ func (db *DB) pageResult(key keys.Key) (*PageResult, error) {
 		return nil, fmt.Errorf("Not HTML Page for: %s", key)
 	}
 
-	return &PageResult{
+	result := &PageResult{
 		Key:         key,
 		URL:         page.URL,
 		Title:       page.Html.Meta.Title,
 		Description: page.Html.Meta.Description,
-	}, nil
+	}
+	favicon := crawler.FaviconKey(page.URL.Scheme, page.URL.Host)
+	if db.CrawlerDB.GetType(favicon) == crawldatabase.TypeFileFavicon {
+		result.Favicon = favicon
+	}
+
+	return result, nil
+}
+
+// Get a favicon from the crawler database.
+func (db *DB) Favicon(key keys.Key) (*crawler.Favicon, error) {
+	page, _, err := db.CrawlerDB.GetValue(key)
+	if err != nil {
+		return nil, err
+	} else if page.Favicon == nil {
+		return nil, fmt.Errorf("Not favicon for: %s", key)
+	}
+	return page.Favicon, nil
 }
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/HuguesGuilleus/isty-search/common"
+	"github.com/HuguesGuilleus/isty-search/crawler"
+	crawldatabase "github.com/HuguesGuilleus/isty-search/crawler/database"
+	"github.com/HuguesGuilleus/isty-search/crawler/htmlnode"
 	"github.com/HuguesGuilleus/isty-search/keys"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +19,7 @@ func TestDBSearch(t *testing.T) {
 		pageResults = append(pageResults, PageResult{
 			Key:         keys.Key{byte(i)},
 			URL:         *common.ParseURL("https://exemple.org/page-" + istr),
+			Favicon:     crawler.FaviconKey("https", "exemple.org"),
 			Title:       "page:" + istr,
 			Description: "desc:" + istr,
 		})
@@ -34,3 +38,20 @@ func TestDBSearch(t *testing.T) {
 		NumberOfChunck:  6,
 	}, result)
 }
+
+func TestDBPageResultFavicon(t *testing.T) {
+	db := FakeDB()
+	key := keys.NewString("https://other.org/")
+	db.CrawlerDB.SetValue(key, &crawler.Page{
+		URL:  *common.ParseURL("https://other.org/"),
+		Html: &htmlnode.Root{},
+	}, crawldatabase.TypeFileHTML)
+
+	result, err := db.pageResult(key)
+	assert.NoError(t, err)
+	assert.Equal(t, keys.Key{}, result.Favicon)
+
+	result, err = db.pageResult(keys.Key{10})
+	assert.NoError(t, err)
+	assert.Equal(t, crawler.FaviconKey("https", "exemple.org"), result.Favicon)
+}